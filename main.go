@@ -2,74 +2,111 @@ package main
 
 import (
 	"flag"
+	"net/http"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 
+	"github.com/Wattpad/kube-sqs-autoscaler/policy"
 	"github.com/Wattpad/kube-sqs-autoscaler/scale"
 	"github.com/Wattpad/kube-sqs-autoscaler/sqs"
 )
 
 var (
-	pollInterval        time.Duration
-	scaleDownCoolPeriod time.Duration
-	scaleUpCoolPeriod   time.Duration
-	scaleUpMessages     int
-	scaleDownMessages   int
-	maxPods             int
-	minPods             int
-	awsRegion           string
+	pollInterval           time.Duration
+	scaleDownCoolPeriod    time.Duration
+	scaleUpCoolPeriod      time.Duration
+	scaleUpMessages        int
+	scaleDownMessages      int
+	targetMessagesPerPod   int
+	scaleSteps             policy.StepPolicy
+	pidEnabled             bool
+	pidSetpoint            int
+	pidKp                  float64
+	pidKi                  float64
+	pidKd                  float64
+	pidIntegralLimit       float64
+	predictive             string
+	podStartupTime         time.Duration
+	predictiveWindow       time.Duration
+	seasonLength           time.Duration
+	holtWintersAlpha       float64
+	holtWintersBeta        float64
+	holtWintersGamma       float64
+	rateBased              bool
+	rateWindow             time.Duration
+	drainTime              time.Duration
+	maxMessageAge          time.Duration
+	smoothing              string
+	ewmaAlpha              float64
+	medianSamples          int
+	confirmPolls           int
+	maxDeadLetterRatio     float64
+	deadLetterWindow       time.Duration
+	learnThroughput        bool
+	throughputWindow       time.Duration
+	throughputSmoothing    float64
+	targetDrainTime        time.Duration
+	metricsAddress         string
+	visibleWeight          float64
+	inFlightWeight         float64
+	delayedWeight          float64
+	messageGroupsMetric    string
+	scaleToZeroAfter       time.Duration
+	activationReplicas     int
+	readinessTimeout       time.Duration
+	scaleUpStabilization   time.Duration
+	scaleDownStabilization time.Duration
+	scaleUpLimits          scale.RateLimits
+	scaleDownLimits        scale.RateLimits
+	scaleUpSelect          = scale.SelectMax
+	scaleDownSelect        = scale.SelectMax
+	sqsQueues              queueFlags
+	queueAggregation       = sqs.Sum
+	schedules              policy.Schedules
+	budgetPodHours         float64
+	budgetCost             float64
+	podHourlyPrice         float64
+	budgetPeriod           = policy.Daily
+	budgetPacing           = policy.Burst
+	competitionPolicy      policy.CompetitionPolicy
+	yieldPeriod            time.Duration
+	instanceID             string
+	jobTemplate            string
+	messagesPerJob         int
+	successfulJobsHistory  int
+	failedJobsHistory      int
+	maxPods                int
+	minPods                int
+	awsRegion              string
+	configFile             string
 
 	sqsQueueUrl              string
 	kubernetesDeploymentName string
 	kubernetesNamespace      string
+	kubernetesKind           = scale.KindDeployment
 )
 
-func Run(p *scale.PodAutoScaler, sqs *sqs.SqsClient) {
-	lastScaleUpTime := time.Now()
-	lastScaleDownTime := time.Now()
-
-	for {
-		select {
-		case <-time.After(pollInterval):
-			{
-				numMessages, err := sqs.NumMessages()
-				if err != nil {
-					log.Errorf("Failed to get SQS messages: %v", err)
-					continue
-				}
-
-				if numMessages >= scaleUpMessages {
-					if lastScaleUpTime.Add(scaleUpCoolPeriod).After(time.Now()) {
-						log.Info("Waiting for cool down, skipping scale up ")
-						continue
-					}
-
-					if err := p.ScaleUp(); err != nil {
-						log.Errorf("Failed scaling up: %v", err)
-						continue
-					}
-
-					lastScaleUpTime = time.Now()
-				}
-
-				if numMessages <= scaleDownMessages {
-					if lastScaleDownTime.Add(scaleDownCoolPeriod).After(time.Now()) {
-						log.Info("Waiting for cool down, skipping scale down")
-						continue
-					}
-
-					if err := p.ScaleDown(); err != nil {
-						log.Errorf("Failed scaling down: %v", err)
-						continue
-					}
-
-					lastScaleDownTime = time.Now()
-				}
-			}
-		}
+// Run scales the target described by the command line flags.
+func Run(p *scale.PodAutoScaler, queue sqs.Queue) {
+	t := flagTarget()
+	t.Run(p, queue)
+}
+
+// desiredReplicas returns the number of replicas needed so that each pod has at
+// most target messages of backlog, bounded by min and max.
+func desiredReplicas(numMessages int, target int, min int, max int) int {
+	desired := (numMessages + target - 1) / target
+
+	if desired > max {
+		return max
+	}
+	if desired < min {
+		return min
 	}
 
+	return desired
 }
 
 func main() {
@@ -78,19 +115,137 @@ func main() {
 	flag.DurationVar(&scaleUpCoolPeriod, "scale-up-cool-down", 10*time.Second, "The cool down period for scaling up")
 	flag.IntVar(&scaleUpMessages, "scale-up-messages", 100, "Number of sqs messages queued up required for scaling up")
 	flag.IntVar(&scaleDownMessages, "scale-down-messages", 10, "Number of messages required to scale down")
+	flag.IntVar(&targetMessagesPerPod, "target-messages-per-pod", 0, "Target number of sqs messages per pod. When set, replicas are scaled directly to backlog divided by this target instead of one at a time")
+	flag.Var(&scaleSteps, "scale-steps", "Comma separated step scaling policies in the form lower:upper:adjustment, e.g. 0:10:-1,100:1000:+1,1000::+50%. Overrides --scale-up-messages and --scale-down-messages")
+	flag.BoolVar(&pidEnabled, "pid", false, "Scale with a PID controller that targets --pid-setpoint instead of thresholds")
+	flag.IntVar(&pidSetpoint, "pid-setpoint", 100, "Queue depth the PID controller aims for")
+	flag.Float64Var(&pidKp, "pid-kp", 0.01, "Proportional gain of the PID controller, in pods per message")
+	flag.Float64Var(&pidKi, "pid-ki", 0.0001, "Integral gain of the PID controller, in pods per message second")
+	flag.Float64Var(&pidKd, "pid-kd", 0, "Derivative gain of the PID controller, in pod seconds per message")
+	flag.Float64Var(&pidIntegralLimit, "pid-integral-limit", 100000, "Bound on the magnitude of the PID integral term in message seconds. Zero means unbounded")
+	flag.StringVar(&predictive, "predictive", "", "Scale for the queue depth forecast at now plus --pod-startup-time. One of linear or holt-winters. Requires --target-messages-per-pod")
+	flag.DurationVar(&podStartupTime, "pod-startup-time", 2*time.Minute, "Time a new pod takes to start consuming messages, used as the predictive forecast horizon")
+	flag.DurationVar(&predictiveWindow, "predictive-window", time.Hour, "Length of the queue depth history used for forecasting")
+	flag.DurationVar(&seasonLength, "holt-winters-season", 24*time.Hour, "Length of one season for the holt-winters forecast. Two seasons of history are kept")
+	flag.Float64Var(&holtWintersAlpha, "holt-winters-alpha", 0.5, "Level smoothing factor for the holt-winters forecast")
+	flag.Float64Var(&holtWintersBeta, "holt-winters-beta", 0.1, "Trend smoothing factor for the holt-winters forecast")
+	flag.Float64Var(&holtWintersGamma, "holt-winters-gamma", 0.3, "Seasonal smoothing factor for the holt-winters forecast")
+	flag.BoolVar(&rateBased, "rate-based", false, "Only scale up while the queue is above --scale-up-messages and growing, and scale down once it is below --scale-down-messages or draining faster than --drain-time")
+	flag.DurationVar(&rateWindow, "rate-window", time.Minute, "Window of queue depth samples used to measure the net inflow rate")
+	flag.DurationVar(&drainTime, "drain-time", 5*time.Minute, "Scale down when the backlog would be drained within this time at the current rate")
+	flag.DurationVar(&maxMessageAge, "max-message-age", 0, "Latency SLO for the age of the oldest message in the queue. Scale up whenever it is exceeded, regardless of the number of messages. Disabled when zero")
+	flag.StringVar(&smoothing, "smoothing", "", "Smooth the backlog before scaling decisions: ewma or median. Disabled when empty")
+	flag.Float64Var(&ewmaAlpha, "ewma-alpha", 0.3, "Weight of each new sample in the ewma smoothing, between 0 and 1")
+	flag.IntVar(&medianSamples, "median-samples", 5, "Number of samples in the moving median smoothing")
+	flag.IntVar(&confirmPolls, "confirm-polls", 1, "Number of consecutive polls that must recommend scaling in the same direction before scaling")
+	flag.Float64Var(&maxDeadLetterRatio, "max-dead-letter-ratio", 0, "Suppress scaling up while more than this share of the messages leaving the queue go to its dead-letter queue, found from the RedrivePolicy. Between 0 and 1, disabled when zero")
+	flag.DurationVar(&deadLetterWindow, "dead-letter-window", 5*time.Minute, "Window over which dead-letter queue inflow is compared with processed messages")
+	flag.BoolVar(&learnThroughput, "learn-throughput", false, "Learn how many messages per second one pod drains and scale to drain the backlog within --target-drain-time. The learned throughput is saved on the deployment")
+	flag.DurationVar(&throughputWindow, "throughput-window", 15*time.Minute, "Window of queue depth samples used to learn the throughput")
+	flag.Float64Var(&throughputSmoothing, "throughput-smoothing", 0.2, "Weight of each new estimate in the learned throughput, between 0 and 1")
+	flag.DurationVar(&targetDrainTime, "target-drain-time", 5*time.Minute, "Time the backlog should be drained within at the learned throughput")
+	flag.StringVar(&metricsAddress, "metrics-address", "", "Address to serve metrics on at /debug/vars, e.g. :8080. Disabled when empty")
+	flag.Float64Var(&visibleWeight, "visible-weight", 1, "Weight of visible messages (ApproximateNumberOfMessages) in the backlog")
+	flag.Float64Var(&inFlightWeight, "in-flight-weight", 0, "Weight of in-flight messages (ApproximateNumberOfMessagesNotVisible) in the backlog")
+	flag.Float64Var(&delayedWeight, "delayed-weight", 0, "Weight of delayed messages (ApproximateNumberOfMessagesDelayed) in the backlog")
+	flag.StringVar(&messageGroupsMetric, "message-groups-metric", "", "CloudWatch metric in the form namespace/name that the consumers of a FIFO queue publish the number of active message groups to, with a QueueName dimension. Scale ups are capped at the number of active groups")
+	flag.DurationVar(&scaleToZeroAfter, "scale-to-zero-after", 0, "Scale the deployment to zero replicas once the queue, including in-flight and delayed messages, has been empty for this long. Disabled when zero")
+	flag.IntVar(&activationReplicas, "activation-replicas", 1, "Replicas to wake a deployment scaled to zero up to as soon as a message arrives, bypassing the scale up cool down")
+	flag.DurationVar(&readinessTimeout, "readiness-timeout", 0, "Hold back scale ups while replicas added earlier are not available yet, for at most this long. Applies to deployments and statefulsets, disabled when zero")
+	flag.DurationVar(&scaleDownStabilization, "scale-down-stabilization", 0, "Only scale down to the highest replica count recommended within this window")
+	flag.DurationVar(&scaleUpStabilization, "scale-up-stabilization", 0, "Only scale up to the lowest replica count recommended within this window")
+	flag.Var(&scaleUpLimits, "scale-up-limits", "Comma separated limits on pods added per period in the form value/period, e.g. 10/1m,100%/1m")
+	flag.Var(&scaleDownLimits, "scale-down-limits", "Comma separated limits on pods removed per period in the form value/period, e.g. 10%/5m")
+	flag.Var(&scaleUpSelect, "scale-up-select", "Which scale up limit applies when there are several: max allows the largest change, min the smallest")
+	flag.Var(&scaleDownSelect, "scale-down-select", "Which scale down limit applies when there are several: max allows the largest change, min the smallest")
+	flag.Var(&schedules, "schedule", "Overrides of the pod limits and thresholds while a cron expression matches, in the form cron[;name=name][;timezone=zone][;min-pods=n][;max-pods=n][;scale-up-messages=n][;scale-down-messages=n][;target-messages-per-pod=n], e.g. '* 8-19 * * mon-fri;timezone=America/Toronto;min-pods=5'. May be given multiple times, the first active schedule wins")
+	flag.Float64Var(&budgetPodHours, "budget-pod-hours", 0, "Most pod-hours the deployment may use per --budget-period. Max pods is lowered as the budget runs out, so that it lasts until the end of the period. Disabled when zero")
+	flag.Float64Var(&budgetCost, "budget-cost", 0, "Budget per --budget-period in cost instead of pod-hours, at --pod-hourly-price")
+	flag.Float64Var(&podHourlyPrice, "pod-hourly-price", 0, "Cost of running one pod for an hour, for --budget-cost")
+	flag.Var(&budgetPeriod, "budget-period", "How often the budget is renewed, at midnight UTC: day or month")
+	flag.Var(&budgetPacing, "budget-pacing", "How the budget is spread over the period: burst only lowers max pods as the budget runs out, even spreads it evenly over the rest of the period")
+	flag.Var(&competitionPolicy, "competition-policy", "What to do when the replicas are changed by someone else, or a HorizontalPodAutoscaler or another kube-sqs-autoscaler instance scales the same resource: yield, override or alert. Disabled when empty, unless --instance-id is set")
+	flag.DurationVar(&yieldPeriod, "yield-period", 10*time.Minute, "How long to leave the replicas alone after someone else changed them, with --competition-policy=yield")
+	flag.StringVar(&instanceID, "instance-id", "", "Name this kube-sqs-autoscaler instance claims the resources it scales with, which turns on --competition-policy=override if no policy is given. Defaults to the hostname, which is the pod name")
+	flag.StringVar(&jobTemplate, "job-template", "", "YAML or JSON Job manifest. When set, the backlog is worked off by creating Jobs from it instead of scaling --kubernetes-deployment, which then names the Jobs. --max-pods limits the running Jobs")
+	flag.IntVar(&messagesPerJob, "messages-per-job", 1, "Number of messages each Job processes before it exits")
+	flag.IntVar(&successfulJobsHistory, "successful-jobs-history", 100, "Number of succeeded Jobs to keep. Older ones are deleted with their pods")
+	flag.IntVar(&failedJobsHistory, "failed-jobs-history", 100, "Number of failed Jobs to keep. Older ones are deleted with their pods")
 	flag.IntVar(&maxPods, "max-pods", 5, "Max pods that kube-sqs-autoscaler can scale")
 	flag.IntVar(&minPods, "min-pods", 1, "Min pods that kube-sqs-autoscaler can scale")
 	flag.StringVar(&awsRegion, "aws-region", "", "Your AWS region")
 
 	flag.StringVar(&sqsQueueUrl, "sqs-queue-url", "", "The sqs queue url")
+	flag.Var(&sqsQueues, "sqs-queue", "An additional sqs queue consumed by the deployment, in the form url[,region=region][,weight=weight]. May be given multiple times")
+	flag.Var(&queueAggregation, "queue-aggregation", "How the backlogs of several queues are combined: sum, max or weighted-sum")
 	flag.StringVar(&kubernetesDeploymentName, "kubernetes-deployment", "", "Kubernetes Deployment to scale. This field is required")
+	flag.Var(&kubernetesKind, "kubernetes-kind", "Kind of resource --kubernetes-deployment names: deployment, statefulset, or Kind.version.group for any resource with a scale subresource")
 	flag.StringVar(&kubernetesNamespace, "kubernetes-namespace", "default", "The namespace your deployment is running in")
 
+	flag.StringVar(&configFile, "config", "", "YAML or JSON file with a list of targets to scale. The other flags are the defaults for every target")
+
 	flag.Parse()
 
-	p := scale.NewPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
-	sqs := sqs.NewSqsClient(sqsQueueUrl, awsRegion)
+	targets := []Target{flagTarget()}
+	if configFile != "" {
+		var err error
+		targets, err = loadTargets(configFile, flagTarget())
+		if err != nil {
+			log.Fatalf("Failed to load targets: %v", err)
+		}
+	}
+
+	for i := range targets {
+		if err := targets[i].Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	client := scale.NewKubeClient()
+
+	var jobClient scale.JobClient
+	for i := range targets {
+		if targets[i].Job.Template != "" {
+			jobClient = scale.NewJobClient(client)
+			break
+		}
+	}
+
+	if metricsAddress != "" {
+		go func() {
+			log.Fatal(http.ListenAndServe(metricsAddress, nil))
+		}()
+	}
 
 	log.Info("Starting kube-sqs-autoscaler")
-	Run(p, sqs)
+
+	var wg sync.WaitGroup
+	for i := range targets {
+		t := &targets[i]
+		queue := t.NewQueue()
+
+		if t.Job.Template != "" {
+			j, err := t.NewJobScaler(jobClient)
+			if err != nil {
+				log.Fatalf("Failed to create jobs for %s: %v", t, err)
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				t.RunJobs(j, queue)
+			}()
+			continue
+		}
+
+		p := t.NewPodAutoScaler(client)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.Run(p, queue)
+		}()
+	}
+
+	wg.Wait()
 }
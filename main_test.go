@@ -1,18 +1,24 @@
 package main
 
 import (
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 
 	"k8s.io/kubernetes/pkg/api"
-	"k8s.io/kubernetes/pkg/apis/extensions"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/autoscaling"
+	"k8s.io/kubernetes/pkg/apis/batch"
 	kclient "k8s.io/kubernetes/pkg/client/unversioned"
 	"k8s.io/kubernetes/pkg/watch"
 
+	"github.com/Wattpad/kube-sqs-autoscaler/policy"
 	"github.com/Wattpad/kube-sqs-autoscaler/scale"
 	mainsqs "github.com/Wattpad/kube-sqs-autoscaler/sqs"
 )
@@ -44,8 +50,8 @@ func TestRunReachMinReplicas(t *testing.T) {
 	s.Client.SetQueueAttributes(input)
 
 	time.Sleep(10 * time.Second)
-	deployment, _ := p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(minPods), deployment.Spec.Replicas, "Number of replicas should be the min")
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(minPods), deployment.Replicas, "Number of replicas should be the min")
 }
 
 func TestRunReachMaxReplicas(t *testing.T) {
@@ -76,8 +82,8 @@ func TestRunReachMaxReplicas(t *testing.T) {
 	s.Client.SetQueueAttributes(input)
 
 	time.Sleep(10 * time.Second)
-	deployment, _ := p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(maxPods), deployment.Spec.Replicas, "Number of replicas should be the max")
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(maxPods), deployment.Replicas, "Number of replicas should be the max")
 }
 
 func TestRunScaleUpCoolDown(t *testing.T) {
@@ -106,8 +112,8 @@ func TestRunScaleUpCoolDown(t *testing.T) {
 	s.Client.SetQueueAttributes(input)
 
 	time.Sleep(15 * time.Second)
-	deployment, _ := p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(4), deployment.Spec.Replicas, "Number of replicas should be 4 if cool down for scaling up was obeyed")
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(4), deployment.Replicas, "Number of replicas should be 4 if cool down for scaling up was obeyed")
 }
 
 func TestRunScaleDownCoolDown(t *testing.T) {
@@ -137,64 +143,543 @@ func TestRunScaleDownCoolDown(t *testing.T) {
 	s.Client.SetQueueAttributes(input)
 
 	time.Sleep(15 * time.Second)
-	deployment, _ := p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(2), deployment.Spec.Replicas, "Number of replicas should be 2 if cool down for scaling down was obeyed")
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(2), deployment.Replicas, "Number of replicas should be 2 if cool down for scaling down was obeyed")
 }
 
-type MockDeployment struct {
-	client *MockKubeClient
+func TestRunTargetTracking(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = 1 * time.Second
+	scaleUpMessages = 100
+	scaleDownMessages = 10
+	targetMessagesPerPod = 50
+	maxPods = 40
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	sqsQueueUrl = "example.com"
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	defer func() { targetMessagesPerPod = 0 }()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	s := NewMockSqsClient()
+
+	go Run(p, s)
+
+	Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String("1010")}
+
+	input := &sqs.SetQueueAttributesInput{
+		Attributes: Attributes,
+	}
+	s.Client.SetQueueAttributes(input)
+
+	time.Sleep(3 * time.Second)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(21), deployment.Replicas, "Number of replicas should jump straight to backlog divided by target")
+}
+
+func TestRunStepScaling(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = 1 * time.Second
+	scaleUpMessages = 100
+	scaleDownMessages = 10
+	maxPods = 40
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	sqsQueueUrl = "example.com"
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	scaleSteps, _ = policy.ParseSteps("0:10:-1,100:1000:+1,1000::+10")
+	defer func() { scaleSteps = nil }()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	s := NewMockSqsClient()
+
+	go Run(p, s)
+
+	Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String("5000")}
+
+	input := &sqs.SetQueueAttributesInput{
+		Attributes: Attributes,
+	}
+	s.Client.SetQueueAttributes(input)
+
+	time.Sleep(1500 * time.Millisecond)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(13), deployment.Replicas, "Number of replicas should grow by the step for the largest band")
+}
+
+func TestRunPID(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = 1 * time.Second
+	maxPods = 40
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	sqsQueueUrl = "example.com"
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	pidEnabled = true
+	pidSetpoint = 100
+	pidKp = 0.01
+	pidKi = 0
+	pidKd = 0
+	defer func() { pidEnabled = false }()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	s := NewMockSqsClient()
+
+	go Run(p, s)
+
+	Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String("1100")}
+
+	input := &sqs.SetQueueAttributesInput{
+		Attributes: Attributes,
+	}
+	s.Client.SetQueueAttributes(input)
+
+	time.Sleep(1500 * time.Millisecond)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(10), deployment.Replicas, "Number of replicas should follow the PID output")
+}
+
+func TestRunPredictive(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = 1 * time.Second
+	targetMessagesPerPod = 100
+	maxPods = 40
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	sqsQueueUrl = "example.com"
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	predictive = "linear"
+	podStartupTime = 10 * time.Second
+	predictiveWindow = time.Minute
+	defer func() {
+		predictive = ""
+		targetMessagesPerPod = 0
+	}()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	s := NewMockSqsClient()
+
+	go Run(p, s)
+
+	// The queue grows by 100 messages per second
+	for _, messages := range []string{"100", "200", "300"} {
+		Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String(messages)}
+		s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{Attributes: Attributes})
+		time.Sleep(1 * time.Second)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.True(t, deployment.Replicas > 3, "Number of replicas should be sized for the forecast rather than the current backlog")
+}
+
+func TestRunRateBased(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = 1 * time.Second
+	scaleUpMessages = 100
+	scaleDownMessages = 10
+	maxPods = 5
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	sqsQueueUrl = "example.com"
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	rateBased = true
+	rateWindow = time.Minute
+	drainTime = time.Second
+	defer func() { rateBased = false }()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	s := NewMockSqsClient()
+
+	go Run(p, s)
+
+	// A large backlog that is slowly draining should not cause a scale up
+	for _, messages := range []string{"10000", "9990", "9980", "9970"} {
+		Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String(messages)}
+		s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{Attributes: Attributes})
+		time.Sleep(1 * time.Second)
+	}
+
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(3), deployment.Replicas, "Number of replicas should not change while the backlog drains")
+}
+
+func TestRunLatencySLO(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = 1 * time.Second
+	scaleUpMessages = 100
+	scaleDownMessages = 10
+	maxPods = 5
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	sqsQueueUrl = "example.com"
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	maxMessageAge = 2 * time.Minute
+	defer func() { maxMessageAge = 0 }()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	s := NewMockSqsClient()
+
+	go Run(p, s)
+
+	// Only a few messages are queued, but the oldest has waited too long
+	Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String("20")}
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{Attributes: Attributes})
+	s.CloudWatch.(*MockCloudWatch).Age = 5 * time.Minute
+
+	time.Sleep(1500 * time.Millisecond)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(4), deployment.Replicas, "Number of replicas should increase when the latency SLO is exceeded")
+}
+
+func TestRunScaleToZero(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = time.Minute
+	scaleUpMessages = 100
+	scaleDownMessages = 10
+	maxPods = 5
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	sqsQueueUrl = "example.com"
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	scaleToZeroAfter = 1 * time.Second
+	activationReplicas = 2
+	defer func() { scaleToZeroAfter = 0 }()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	s := NewMockSqsClient()
+
+	go Run(p, s)
+
+	Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String("0")}
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{Attributes: Attributes})
+
+	time.Sleep(3500 * time.Millisecond)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(0), deployment.Replicas, "Number of replicas should be zero after the queue was idle")
+
+	// A single message wakes the deployment up despite the long scale up cool down
+	Attributes = map[string]*string{"ApproximateNumberOfMessages": aws.String("1")}
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{Attributes: Attributes})
+
+	time.Sleep(1 * time.Second)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(2), deployment.Replicas, "Number of replicas should be the activation replicas")
+}
+
+func TestRunScaleDownStabilization(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = 1 * time.Second
+	scaleUpMessages = 100
+	scaleDownMessages = 10
+	maxPods = 5
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	sqsQueueUrl = "example.com"
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	scaleDownStabilization = 10 * time.Second
+	defer func() { scaleDownStabilization = 0 }()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	s := NewMockSqsClient()
+
+	go Run(p, s)
+
+	Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String("10")}
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{Attributes: Attributes})
+
+	// Each poll recommends one replica less than the current count, so the
+	// highest recommendation in the window only allows the first scale down.
+	time.Sleep(5 * time.Second)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(2), deployment.Replicas, "Number of replicas should only scale down once within the stabilization window")
+}
+
+func TestRunMultiQueue(t *testing.T) {
+	pollInterval = 1 * time.Second
+	scaleDownCoolPeriod = 1 * time.Second
+	scaleUpCoolPeriod = 1 * time.Second
+	scaleUpMessages = 100
+	scaleDownMessages = 10
+	targetMessagesPerPod = 50
+	maxPods = 40
+	minPods = 1
+	awsRegion = "us-east-1"
+
+	kubernetesDeploymentName = "test"
+	kubernetesNamespace = "test"
+
+	defer func() { targetMessagesPerPod = 0 }()
+
+	p := NewMockPodAutoScaler(kubernetesDeploymentName, kubernetesNamespace, maxPods, minPods)
+	high := NewMockSqsClient()
+	bulk := NewMockSqsClient()
+
+	q := &mainsqs.MultiQueue{
+		Queues: []mainsqs.WeightedQueue{
+			{Queue: high, Weight: 4},
+			{Queue: bulk, Weight: 1},
+		},
+		Aggregation: mainsqs.WeightedSum,
+	}
+
+	go Run(p, q)
+
+	high.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("100")},
+	})
+	bulk.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("200")},
+	})
+
+	time.Sleep(1500 * time.Millisecond)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(12), deployment.Replicas, "Number of replicas should be sized for the weighted sum of both queues")
+}
+
+func TestQueueFlags(t *testing.T) {
+	var specs queueFlags
+
+	assert.Nil(t, specs.Set("https://sqs.us-east-1.amazonaws.com/1/high,weight=4"))
+	assert.Nil(t, specs.Set("https://sqs.eu-west-1.amazonaws.com/1/bulk,region=eu-west-1"))
+	assert.Equal(t, queueFlags{
+		{URL: "https://sqs.us-east-1.amazonaws.com/1/high", Weight: 4},
+		{URL: "https://sqs.eu-west-1.amazonaws.com/1/bulk", Region: "eu-west-1", Weight: 1},
+	}, specs)
+
+	assert.NotNil(t, specs.Set("https://example.com,weight=heavy"))
+	assert.NotNil(t, specs.Set("https://example.com,priority=1"))
+	assert.NotNil(t, specs.Set("https://example.com,region"))
+}
+
+func TestDesiredReplicas(t *testing.T) {
+	assert.Equal(t, 1, desiredReplicas(0, 50, 1, 40))
+	assert.Equal(t, 1, desiredReplicas(50, 50, 1, 40))
+	assert.Equal(t, 2, desiredReplicas(51, 50, 1, 40))
+	assert.Equal(t, 40, desiredReplicas(50000, 50, 1, 40))
+}
+
+// MockObject is a Deployment, StatefulSet or other resource scaled through
+// its scale subresource, as the api server stores it.
+type MockObject struct {
+	Replicas    int32
+	Annotations map[string]string
 }
 
 type MockKubeClient struct {
-	// stores the state of Deployment as if the api server did
-	Deployment *extensions.Deployment
+	// Objects stores the scaled resources by resource, e.g. deployments, as
+	// if the api server did. Other resources are created on first use with
+	// 3 replicas.
+	Objects map[string]*MockObject
+	// Status is the status of every resource. When nil, they are settled
+	// with all their replicas available
+	Status *scale.Status
+
+	// Conflicts is the number of scale updates that fail with a conflict
+	Conflicts int
+
+	// HPAs are the HorizontalPodAutoscalers in the namespace
+	HPAs []autoscaling.HorizontalPodAutoscaler
+}
+
+func (m *MockKubeClient) object(resource string) *MockObject {
+	o, ok := m.Objects[resource]
+	if !ok {
+		o = &MockObject{Replicas: 3}
+		m.Objects[resource] = o
+	}
+	return o
 }
 
-func (m *MockDeployment) Get(name string) (*extensions.Deployment, error) {
-	return m.client.Deployment, nil
+// Deployment returns the deployment scaled by default.
+func (m *MockKubeClient) Deployment() *MockObject {
+	return m.object("deployments")
 }
 
-func (m *MockDeployment) Update(deployment *extensions.Deployment) (*extensions.Deployment, error) {
-	m.client.Deployment.Spec.Replicas = deployment.Spec.Replicas
-	return m.client.Deployment, nil
+// StatefulSet returns the statefulset scaled with KindStatefulSet.
+func (m *MockKubeClient) StatefulSet() *MockObject {
+	return m.object("statefulsets")
 }
 
-func (m *MockDeployment) List(opts api.ListOptions) (*extensions.DeploymentList, error) {
+// MockScale reads and writes the replicas and annotations of the resource
+// the scale subresource belongs to.
+type MockScale struct {
+	client   *MockKubeClient
+	resource string
+}
+
+func (m *MockScale) Get(name string) (*scale.Scale, error) {
+	return &scale.Scale{Replicas: int(m.client.object(m.resource).Replicas)}, nil
+}
+
+func (m *MockScale) Update(name string, s *scale.Scale) (*scale.Scale, error) {
+	if m.client.Conflicts > 0 {
+		m.client.Conflicts--
+		return nil, apierrors.NewConflict(unversioned.GroupResource{Resource: m.resource}, name, errors.New("Modified"))
+	}
+
+	m.client.object(m.resource).Replicas = int32(s.Replicas)
+	return m.Get(name)
+}
+
+func (m *MockScale) Status(name string) (*scale.Status, error) {
+	if m.client.Status == nil {
+		replicas := m.client.object(m.resource).Replicas
+		return &scale.Status{Replicas: replicas, UpdatedReplicas: replicas, AvailableReplicas: replicas}, nil
+	}
+	return m.client.Status, nil
+}
+
+func (m *MockScale) Annotations(name string) (map[string]string, error) {
+	return m.client.object(m.resource).Annotations, nil
+}
+
+func (m *MockScale) Annotate(name string, key string, value string) error {
+	o := m.client.object(m.resource)
+	if o.Annotations == nil {
+		o.Annotations = map[string]string{}
+	}
+	o.Annotations[key] = value
+	return nil
+}
+
+func (m *MockKubeClient) Scales(resource scale.Resource, namespace string) scale.ScaleInterface {
+	return &MockScale{
+		client:   m,
+		resource: resource.Resource,
+	}
+}
+
+type MockHorizontalPodAutoscalers struct {
+	client *MockKubeClient
+}
+
+func (m *MockHorizontalPodAutoscalers) List(opts api.ListOptions) (*autoscaling.HorizontalPodAutoscalerList, error) {
+	return &autoscaling.HorizontalPodAutoscalerList{Items: m.client.HPAs}, nil
+}
+
+func (m *MockHorizontalPodAutoscalers) Get(name string) (*autoscaling.HorizontalPodAutoscaler, error) {
 	return nil, nil
 }
 
-func (m *MockDeployment) Delete(name string, options *api.DeleteOptions) error {
+func (m *MockHorizontalPodAutoscalers) Delete(name string, options *api.DeleteOptions) error {
 	return nil
 }
 
-func (m *MockDeployment) Create(*extensions.Deployment) (*extensions.Deployment, error) {
+func (m *MockHorizontalPodAutoscalers) Create(*autoscaling.HorizontalPodAutoscaler) (*autoscaling.HorizontalPodAutoscaler, error) {
 	return nil, nil
 }
 
-func (m *MockDeployment) UpdateStatus(*extensions.Deployment) (*extensions.Deployment, error) {
+func (m *MockHorizontalPodAutoscalers) Update(*autoscaling.HorizontalPodAutoscaler) (*autoscaling.HorizontalPodAutoscaler, error) {
 	return nil, nil
 }
 
-func (m *MockDeployment) Watch(opts api.ListOptions) (watch.Interface, error) {
+func (m *MockHorizontalPodAutoscalers) UpdateStatus(*autoscaling.HorizontalPodAutoscaler) (*autoscaling.HorizontalPodAutoscaler, error) {
 	return nil, nil
 }
 
-func (m *MockDeployment) Rollback(*extensions.DeploymentRollback) error {
-	return nil
+func (m *MockHorizontalPodAutoscalers) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, nil
 }
 
-func (m *MockKubeClient) Deployments(namespace string) kclient.DeploymentInterface {
-	return &MockDeployment{
+func (m *MockKubeClient) HorizontalPodAutoscalers(namespace string) kclient.HorizontalPodAutoscalerInterface {
+	return &MockHorizontalPodAutoscalers{
 		client: m,
 	}
 }
 
+type MockJobClient struct {
+	// stores the Jobs as if the api server did, guarded by mu since the
+	// jobs are created by a running target
+	mu    sync.Mutex
+	Items []batch.Job
+}
+
+// Created returns a copy of the Jobs created so far.
+func (m *MockJobClient) Created() []batch.Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]batch.Job{}, m.Items...)
+}
+
+type MockJobs struct {
+	client *MockJobClient
+}
+
+func (m *MockJobClient) Jobs(namespace string) kclient.JobInterface {
+	return &MockJobs{client: m}
+}
+
+func (m *MockJobs) List(opts api.ListOptions) (*batch.JobList, error) {
+	return &batch.JobList{Items: m.client.Created()}, nil
+}
+
+func (m *MockJobs) Get(name string) (*batch.Job, error) {
+	return nil, nil
+}
+
+func (m *MockJobs) Create(job *batch.Job) (*batch.Job, error) {
+	m.client.mu.Lock()
+	defer m.client.mu.Unlock()
+	m.client.Items = append(m.client.Items, *job)
+	return job, nil
+}
+
+func (m *MockJobs) Update(job *batch.Job) (*batch.Job, error) {
+	return nil, nil
+}
+
+func (m *MockJobs) Delete(name string, options *api.DeleteOptions) error {
+	return nil
+}
+
+func (m *MockJobs) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, nil
+}
+
+func (m *MockJobs) UpdateStatus(job *batch.Job) (*batch.Job, error) {
+	return nil, nil
+}
+
 func NewMockKubeClient() *MockKubeClient {
+	// the resources the run tests read while a target scales them are
+	// created up front
 	return &MockKubeClient{
-		Deployment: &extensions.Deployment{
-			Spec: extensions.DeploymentSpec{
-				Replicas: 3,
-			},
+		Objects: map[string]*MockObject{
+			"deployments":  {Replicas: 3},
+			"statefulsets": {Replicas: 3},
 		},
 	}
 }
@@ -226,6 +711,18 @@ func (m *MockSQS) SetQueueAttributes(input *sqs.SetQueueAttributesInput) (*sqs.S
 	return &sqs.SetQueueAttributesOutput{}, nil
 }
 
+type MockCloudWatch struct {
+	Age time.Duration
+}
+
+func (m *MockCloudWatch) GetMetricStatistics(*mainsqs.GetMetricStatisticsInput) (*mainsqs.GetMetricStatisticsOutput, error) {
+	return &mainsqs.GetMetricStatisticsOutput{
+		Datapoints: []*mainsqs.Datapoint{
+			{Timestamp: aws.Time(time.Now()), Maximum: aws.Float64(m.Age.Seconds())},
+		},
+	}, nil
+}
+
 func NewMockSqsClient() *mainsqs.SqsClient {
 	Attributes := map[string]*string{"ApproximateNumberOfMessages": aws.String("50")}
 
@@ -235,6 +732,7 @@ func NewMockSqsClient() *mainsqs.SqsClient {
 				Attributes: Attributes,
 			},
 		},
-		QueueUrl: "example.com",
+		CloudWatch: &MockCloudWatch{},
+		QueueUrl:   "https://sqs.us-east-1.amazonaws.com/123456789012/test",
 	}
 }
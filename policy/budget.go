@@ -0,0 +1,222 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+// BudgetPeriod is how often a budget is renewed, at midnight UTC.
+type BudgetPeriod string
+
+const (
+	// Daily budgets are renewed every day.
+	Daily BudgetPeriod = "day"
+	// Monthly budgets are renewed on the first day of every month.
+	Monthly BudgetPeriod = "month"
+)
+
+func (p *BudgetPeriod) String() string {
+	return string(*p)
+}
+
+// Set implements flag.Value.
+func (p *BudgetPeriod) Set(v string) error {
+	switch BudgetPeriod(v) {
+	case Daily, Monthly:
+		*p = BudgetPeriod(v)
+		return nil
+	}
+
+	return errors.Errorf("Invalid budget period %q, expected day or month", v)
+}
+
+// UnmarshalJSON rejects unknown budget periods.
+func (p *BudgetPeriod) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	return p.Set(v)
+}
+
+// Start returns the start of the period containing t.
+func (p BudgetPeriod) Start(t time.Time) time.Time {
+	t = t.UTC()
+	if p == Monthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// End returns the end of the period containing t.
+func (p BudgetPeriod) End(t time.Time) time.Time {
+	start := p.Start(t)
+	if p == Monthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// BudgetPacing is how a budget is spread over its period.
+type BudgetPacing string
+
+const (
+	// Burst budgets allow any replicas while the remaining budget covers
+	// them, and only lower the max as the budget runs out.
+	Burst BudgetPacing = "burst"
+	// Even budgets spread the remaining budget evenly over the rest of the
+	// period.
+	Even BudgetPacing = "even"
+)
+
+func (p *BudgetPacing) String() string {
+	return string(*p)
+}
+
+// Set implements flag.Value.
+func (p *BudgetPacing) Set(v string) error {
+	switch BudgetPacing(v) {
+	case Burst, Even:
+		*p = BudgetPacing(v)
+		return nil
+	}
+
+	return errors.Errorf("Invalid budget pacing %q, expected burst or even", v)
+}
+
+// UnmarshalJSON rejects unknown budget pacings.
+func (p *BudgetPacing) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	return p.Set(v)
+}
+
+// burstWindow is how far ahead a burst budget must cover the replicas.
+const burstWindow = time.Hour
+
+// Budget is a ceiling on the pod-hours a deployment may use per period. The
+// consumption is measured from the actual replicas over time, and the
+// replicas are limited so that the remaining budget lasts until the end of
+// the period.
+type Budget struct {
+	PodHours float64
+	Period   BudgetPeriod
+	// Pacing defaults to Burst.
+	Pacing BudgetPacing
+
+	Log *log.Entry
+
+	// Used is the pod-hours consumed since Start.
+	Used  float64
+	Start time.Time
+
+	last         time.Time
+	lastReplicas int
+}
+
+func NewBudget(podHours float64, period BudgetPeriod) *Budget {
+	return &Budget{
+		PodHours: podHours,
+		Period:   period,
+	}
+}
+
+// Observe records that the deployment has replicas at now. The replicas seen
+// on the previous observation are charged for the time in between.
+func (b *Budget) Observe(replicas int, now time.Time) {
+	start := b.Period.Start(now)
+	if !start.Equal(b.Start) {
+		if !b.Start.IsZero() {
+			logger(b.Log).Infof("Budget period %s started, %.2f of %.2f pod-hours were used in the last one", start.Format(time.RFC3339), b.Used, b.PodHours)
+		}
+
+		b.Start = start
+		b.Used = 0
+
+		// Only the part of the interval within the new period counts
+		if b.last.Before(start) && !b.last.IsZero() {
+			b.last = start
+		}
+	}
+
+	if !b.last.IsZero() && now.After(b.last) {
+		b.Used += float64(b.lastReplicas) * now.Sub(b.last).Hours()
+	}
+
+	b.last = now
+	b.lastReplicas = replicas
+}
+
+// Remaining returns the pod-hours left in the current period.
+func (b *Budget) Remaining() float64 {
+	return math.Max(0, b.PodHours-b.Used)
+}
+
+// MaxReplicas returns the most replicas that can run from now on without
+// exceeding the budget by the end of the period. Even budgets allow the
+// replicas that can run until the end of the period. Burst budgets allow the
+// replicas that can run for the next hour while leaving enough to keep min
+// replicas running for the rest of the period, so the max only drops as the
+// budget runs out, and never below that of an even budget.
+func (b *Budget) MaxReplicas(min int, now time.Time) int {
+	hours := b.Period.End(now).Sub(now).Hours()
+	if hours <= 0 {
+		return 0
+	}
+
+	even := int(math.Floor(b.Remaining() / hours))
+	if b.Pacing == Even {
+		return even
+	}
+
+	window := math.Min(burstWindow.Hours(), hours)
+	burst := int(math.Floor((b.Remaining() - float64(min)*(hours-window)) / window))
+	if burst < even {
+		return even
+	}
+	return burst
+}
+
+// State returns the consumption of the current period in the form
+// start/used, so that it can be saved and restored after a restart.
+func (b *Budget) State() string {
+	return fmt.Sprintf("%s/%.4f", b.Start.Format(time.RFC3339), b.Used)
+}
+
+// Restore resumes the consumption saved by State. A state from an earlier
+// period is ignored.
+func (b *Budget) Restore(state string, now time.Time) error {
+	parts := strings.SplitN(state, "/", 2)
+	if len(parts) != 2 {
+		return errors.Errorf("Invalid budget state %q", state)
+	}
+
+	start, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return errors.Errorf("Invalid start in budget state %q", state)
+	}
+
+	used, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return errors.Errorf("Invalid usage in budget state %q", state)
+	}
+
+	if !start.Equal(b.Period.Start(now)) {
+		return nil
+	}
+
+	b.Start = start
+	b.Used = used
+	return nil
+}
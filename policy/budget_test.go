@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetPeriod(t *testing.T) {
+	now := time.Date(2017, 3, 15, 13, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2017, 3, 15, 0, 0, 0, 0, time.UTC), Daily.Start(now))
+	assert.Equal(t, time.Date(2017, 3, 16, 0, 0, 0, 0, time.UTC), Daily.End(now))
+	assert.Equal(t, time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC), Monthly.Start(now))
+	assert.Equal(t, time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC), Monthly.End(now))
+
+	var period BudgetPeriod
+	assert.Nil(t, period.Set("month"))
+	assert.Equal(t, Monthly, period)
+	assert.NotNil(t, period.Set("week"))
+}
+
+func TestBudgetObserve(t *testing.T) {
+	b := NewBudget(48, Daily)
+	b.Pacing = Even
+	now := time.Date(2017, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	// 48 pod-hours over the 12 hours left allow 4 pods
+	b.Observe(4, now)
+	assert.Equal(t, 4, b.MaxReplicas(1, now))
+
+	// Running 8 pods for 3 hours uses half the budget
+	b.Observe(8, now.Add(time.Hour))
+	b.Observe(8, now.Add(4*time.Hour))
+	assert.InDelta(t, 4+24, b.Used, 0.001)
+	assert.InDelta(t, 20, b.Remaining(), 0.001)
+	assert.Equal(t, 2, b.MaxReplicas(1, now.Add(4*time.Hour)))
+
+	// Overspending leaves nothing for the rest of the day
+	b.Observe(0, now.Add(8*time.Hour))
+	assert.Equal(t, 0.0, b.Remaining())
+	assert.Equal(t, 0, b.MaxReplicas(1, now.Add(8*time.Hour)))
+}
+
+func TestBudgetBurst(t *testing.T) {
+	b := NewBudget(100, Daily)
+	now := time.Date(2017, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	// The whole budget is left at midnight, so bursts are not limited
+	b.Observe(40, now)
+	assert.Equal(t, 77, b.MaxReplicas(1, now))
+
+	// After an hour at 40 pods, 60 pod-hours are left. Keeping 1 pod for
+	// the 22 hours after the next one leaves 38 for the next hour
+	b.Observe(38, now.Add(time.Hour))
+	assert.Equal(t, 38, b.MaxReplicas(1, now.Add(time.Hour)))
+
+	// After another hour at 38 pods, only min replicas are left
+	b.Observe(3, now.Add(2*time.Hour))
+	assert.InDelta(t, 22, b.Remaining(), 0.001)
+	assert.Equal(t, 1, b.MaxReplicas(1, now.Add(2*time.Hour)))
+
+	// Running above that spends the budget before the end of the day
+	b.Observe(3, now.Add(8*time.Hour))
+	assert.InDelta(t, 4, b.Remaining(), 0.001)
+	assert.Equal(t, 0, b.MaxReplicas(1, now.Add(8*time.Hour)))
+
+	var pacing BudgetPacing
+	assert.Nil(t, pacing.Set("even"))
+	assert.Equal(t, Even, pacing)
+	assert.NotNil(t, pacing.Set("fast"))
+}
+
+func TestBudgetRenews(t *testing.T) {
+	b := NewBudget(48, Daily)
+	b.Pacing = Even
+	now := time.Date(2017, 3, 15, 23, 0, 0, 0, time.UTC)
+
+	b.Observe(10, now)
+	b.Observe(10, now.Add(30*time.Minute))
+	assert.InDelta(t, 5, b.Used, 0.001)
+
+	// Only the half hour after midnight is charged to the new day
+	b.Observe(10, now.Add(90*time.Minute))
+	assert.Equal(t, time.Date(2017, 3, 16, 0, 0, 0, 0, time.UTC), b.Start)
+	assert.InDelta(t, 5, b.Used, 0.001)
+	assert.Equal(t, 1, b.MaxReplicas(1, now.Add(90*time.Minute)))
+}
+
+func TestBudgetRestore(t *testing.T) {
+	now := time.Date(2017, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	b := NewBudget(48, Daily)
+	b.Observe(4, now)
+	b.Observe(4, now.Add(2*time.Hour))
+	state := b.State()
+
+	restored := NewBudget(48, Daily)
+	assert.Nil(t, restored.Restore(state, now.Add(3*time.Hour)))
+	assert.InDelta(t, 8, restored.Used, 0.001)
+
+	// Usage from yesterday does not count today
+	fresh := NewBudget(48, Daily)
+	assert.Nil(t, fresh.Restore(state, now.Add(24*time.Hour)))
+	assert.Equal(t, 0.0, fresh.Used)
+
+	assert.NotNil(t, fresh.Restore("yesterday", now))
+	assert.NotNil(t, fresh.Restore("2017-03-15T00:00:00Z/lots", now))
+}
@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+// CompetitionPolicy is what an autoscaler does when something else scales
+// the same resource: a person or tool that changes the replicas, a
+// HorizontalPodAutoscaler or another autoscaler instance.
+type CompetitionPolicy string
+
+const (
+	// Yield leaves the replicas alone while a competing controller exists,
+	// and for a while after the replicas were changed by someone else.
+	Yield CompetitionPolicy = "yield"
+	// Override keeps scaling and takes the resource over from other
+	// autoscaler instances.
+	Override CompetitionPolicy = "override"
+	// Alert keeps scaling and logs an error on every poll while the
+	// competition lasts, without taking the resource over.
+	Alert CompetitionPolicy = "alert"
+)
+
+func (p *CompetitionPolicy) String() string {
+	return string(*p)
+}
+
+// Set implements flag.Value.
+func (p *CompetitionPolicy) Set(v string) error {
+	switch CompetitionPolicy(v) {
+	case Yield, Override, Alert:
+		*p = CompetitionPolicy(v)
+		return nil
+	}
+
+	return errors.Errorf("Invalid competition policy %q, expected yield, override or alert", v)
+}
+
+// UnmarshalJSON rejects unknown competition policies.
+func (p *CompetitionPolicy) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	return p.Set(v)
+}
+
+// Competition applies a CompetitionPolicy to what is found scaling the same
+// resource on each poll. An empty policy overrides.
+type Competition struct {
+	Policy CompetitionPolicy
+	// YieldPeriod is how long to leave the replicas alone after someone
+	// else changed them.
+	YieldPeriod time.Duration
+
+	Log *log.Entry
+
+	yieldUntil time.Time
+	reported   string
+}
+
+func NewCompetition(policy CompetitionPolicy, yieldPeriod time.Duration) *Competition {
+	return &Competition{
+		Policy:      policy,
+		YieldPeriod: yieldPeriod,
+	}
+}
+
+// Observe records a change of the replicas by someone else, if change is not
+// empty, and the competitors found on this poll. It returns true if the
+// autoscaler should leave the replicas alone.
+func (c *Competition) Observe(change string, competitors []string, now time.Time) bool {
+	l := logger(c.Log)
+
+	if change != "" {
+		switch c.Policy {
+		case Yield:
+			c.yieldUntil = now.Add(c.YieldPeriod)
+			l.Warnf("%s, yielding until %s", change, c.yieldUntil.UTC().Format(time.RFC3339))
+		case Alert:
+			l.Error(change)
+		default:
+			l.Warnf("%s, overriding", change)
+		}
+	}
+
+	current := strings.Join(competitors, ", ")
+	switch {
+	case current != "" && c.Policy == Alert:
+		l.Errorf("Competing with %s", current)
+	case current != "" && current != c.reported && c.Policy == Yield:
+		l.Warnf("Competing with %s, yielding", current)
+	case current != "" && current != c.reported:
+		l.Warnf("Competing with %s, overriding", current)
+	case current == "" && c.reported != "":
+		l.Infof("No longer competing with %s", c.reported)
+	}
+	c.reported = current
+
+	if c.Policy != Yield {
+		return false
+	}
+	return current != "" || now.Before(c.yieldUntil)
+}
+
+// TakesOver returns whether the autoscaler should claim the resource from
+// another autoscaler instance that is still scaling it.
+func (c *Competition) TakesOver() bool {
+	return c.Policy != Yield && c.Policy != Alert
+}
@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompetitionPolicySet(t *testing.T) {
+	var p CompetitionPolicy
+	assert.Nil(t, p.Set("yield"))
+	assert.Equal(t, Yield, p)
+	assert.NotNil(t, p.Set("ignore"))
+
+	assert.Nil(t, json.Unmarshal([]byte(`"alert"`), &p))
+	assert.Equal(t, Alert, p)
+	assert.NotNil(t, json.Unmarshal([]byte(`"fight"`), &p))
+}
+
+func TestCompetitionYield(t *testing.T) {
+	c := NewCompetition(Yield, 10*time.Minute)
+	now := time.Now()
+
+	assert.False(t, c.Observe("", nil, now))
+	assert.True(t, c.Observe("Replicas changed from 3 to 6", nil, now))
+	assert.True(t, c.Observe("", nil, now.Add(9*time.Minute)), "Still within the yield period")
+	assert.False(t, c.Observe("", nil, now.Add(10*time.Minute)))
+
+	assert.True(t, c.Observe("", []string{"HorizontalPodAutoscaler worker"}, now.Add(time.Hour)))
+	assert.False(t, c.Observe("", nil, now.Add(2*time.Hour)), "The competitor went away")
+	assert.False(t, c.TakesOver())
+}
+
+func TestCompetitionOverrideAndAlert(t *testing.T) {
+	now := time.Now()
+	for _, policy := range []CompetitionPolicy{"", Override, Alert} {
+		c := NewCompetition(policy, 10*time.Minute)
+		assert.False(t, c.Observe("Replicas changed from 3 to 6", nil, now))
+		assert.False(t, c.Observe("", []string{"autoscaler instance other"}, now))
+		assert.Equal(t, policy != Alert, c.TakesOver())
+	}
+}
@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/Wattpad/kube-sqs-autoscaler/sqs"
+)
+
+// PoisonDetector recognizes when most messages fail instead of being
+// processed, e.g. after a bad deploy, by comparing the inflow to the
+// dead-letter queue with the messages deleted from the main queue. Scaling
+// up then only moves messages to the dead-letter queue faster.
+type PoisonDetector struct {
+	// MaxRatio is the largest share of the messages leaving the queue that
+	// may go to the dead-letter queue, between 0 and 1.
+	MaxRatio float64
+	Window   time.Duration
+
+	Log *log.Entry
+
+	samples []depthSample
+	warned  bool
+}
+
+type depthSample struct {
+	time  time.Time
+	depth int
+}
+
+func NewPoisonDetector(maxRatio float64, window time.Duration) *PoisonDetector {
+	return &PoisonDetector{
+		MaxRatio: maxRatio,
+		Window:   window,
+	}
+}
+
+// Poisoned records the dead-letter queue depth and returns true if the
+// inflow to the dead-letter queue within Window exceeds MaxRatio of all
+// messages that left the queue.
+func (d *PoisonDetector) Poisoned(deadLetters sqs.DeadLetters, now time.Time) bool {
+	if !deadLetters.Found {
+		if !d.warned {
+			logger(d.Log).Warn("Queue has no dead-letter queue, poison message detection is disabled")
+			d.warned = true
+		}
+		return false
+	}
+
+	d.samples = append(d.samples, depthSample{time: now, depth: deadLetters.Depth})
+	for len(d.samples) > 0 && now.Sub(d.samples[0].time) > d.Window {
+		d.samples = d.samples[1:]
+	}
+
+	// The dead-letter queue shrinks when it is redriven or purged, which is
+	// not a sign of healthy processing
+	inflow := deadLetters.Depth - d.samples[0].depth
+	if inflow <= 0 {
+		return false
+	}
+
+	ratio := float64(inflow) / float64(inflow+deadLetters.Deleted)
+	if ratio <= d.MaxRatio {
+		return false
+	}
+
+	logger(d.Log).Errorf("POISON MESSAGES: %d messages went to the dead-letter queue and %d were processed within %s (%.0f%% failed, limit %.0f%%)",
+		inflow, deadLetters.Deleted, d.Window, ratio*100, d.MaxRatio*100)
+	return true
+}
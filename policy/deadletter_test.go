@@ -0,0 +1,42 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Wattpad/kube-sqs-autoscaler/sqs"
+)
+
+func TestPoisonDetector(t *testing.T) {
+	d := NewPoisonDetector(0.5, 5*time.Minute)
+	now := time.Now()
+
+	assert.False(t, d.Poisoned(sqs.DeadLetters{Found: true, Depth: 10, Deleted: 100}, now))
+
+	// 20 failed while 100 were processed
+	assert.False(t, d.Poisoned(sqs.DeadLetters{Found: true, Depth: 30, Deleted: 100}, now.Add(time.Minute)))
+
+	// 200 failed while 100 were processed
+	assert.True(t, d.Poisoned(sqs.DeadLetters{Found: true, Depth: 210, Deleted: 100}, now.Add(2*time.Minute)))
+
+	// Once the failures leave the window the queue is healthy again
+	assert.False(t, d.Poisoned(sqs.DeadLetters{Found: true, Depth: 210, Deleted: 100}, now.Add(10*time.Minute)))
+}
+
+func TestPoisonDetectorRedrive(t *testing.T) {
+	d := NewPoisonDetector(0.5, 5*time.Minute)
+	now := time.Now()
+
+	d.Poisoned(sqs.DeadLetters{Found: true, Depth: 1000}, now)
+	assert.False(t, d.Poisoned(sqs.DeadLetters{Found: true, Depth: 0}, now.Add(time.Minute)), "A redriven dead-letter queue is not poison")
+}
+
+func TestPoisonDetectorWithoutDeadLetterQueue(t *testing.T) {
+	d := NewPoisonDetector(0.5, 5*time.Minute)
+	now := time.Now()
+
+	assert.False(t, d.Poisoned(sqs.DeadLetters{Deleted: 0}, now))
+	assert.False(t, d.Poisoned(sqs.DeadLetters{Deleted: 0}, now.Add(time.Minute)))
+}
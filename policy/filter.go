@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Smoother conditions a noisy series of queue depths before scaling
+// decisions are made from it.
+type Smoother interface {
+	Smooth(value float64) float64
+}
+
+// EWMA is an exponentially weighted moving average. Alpha is the weight of
+// each new value, between 0 and 1.
+type EWMA struct {
+	Alpha float64
+
+	value   float64
+	started bool
+}
+
+func (e *EWMA) Smooth(value float64) float64 {
+	if !e.started {
+		e.value = value
+		e.started = true
+		return value
+	}
+
+	e.value = e.Alpha*value + (1-e.Alpha)*e.value
+	return e.value
+}
+
+// MovingMedian is the median of the last Size values, which ignores single
+// spikes entirely.
+type MovingMedian struct {
+	Size int
+
+	values []float64
+}
+
+func (m *MovingMedian) Smooth(value float64) float64 {
+	m.values = append(m.values, value)
+	if len(m.values) > m.Size {
+		m.values = m.values[len(m.values)-m.Size:]
+	}
+
+	sorted := append([]float64{}, m.values...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// NewSmoother returns the smoother of the given kind: ewma, median, or none
+// for an empty kind.
+func NewSmoother(kind string, alpha float64, size int) (Smoother, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case "ewma":
+		if alpha <= 0 || alpha > 1 {
+			return nil, errors.Errorf("Invalid EWMA alpha %g, expected between 0 and 1", alpha)
+		}
+		return &EWMA{Alpha: alpha}, nil
+	case "median":
+		if size <= 0 {
+			return nil, errors.Errorf("Invalid median samples %d, expected at least 1", size)
+		}
+		return &MovingMedian{Size: size}, nil
+	}
+
+	return nil, errors.Errorf("Unknown smoothing %q, expected ewma or median", kind)
+}
+
+// Confirmation requires the same scaling direction on Polls consecutive polls
+// before it is acted on.
+type Confirmation struct {
+	Polls int
+
+	direction Direction
+	count     int
+}
+
+// Confirm records the direction recommended on this poll and returns true
+// if it has been recommended on enough consecutive polls.
+func (c *Confirmation) Confirm(d Direction) bool {
+	if d != c.direction {
+		c.direction = d
+		c.count = 0
+	}
+
+	c.count++
+	return d == Hold || c.count >= c.Polls
+}
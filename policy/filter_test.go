@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEWMA(t *testing.T) {
+	e := &EWMA{Alpha: 0.5}
+
+	assert.Equal(t, 100.0, e.Smooth(100))
+	assert.Equal(t, 150.0, e.Smooth(200))
+	assert.Equal(t, 125.0, e.Smooth(100))
+}
+
+func TestMovingMedian(t *testing.T) {
+	m := &MovingMedian{Size: 3}
+
+	assert.Equal(t, 10.0, m.Smooth(10))
+	assert.Equal(t, 15.0, m.Smooth(20))
+
+	// A single spike is ignored
+	assert.Equal(t, 20.0, m.Smooth(5000))
+	assert.Equal(t, 30.0, m.Smooth(30))
+
+	// Only the last Size samples count
+	assert.Equal(t, 40.0, m.Smooth(40))
+}
+
+func TestNewSmoother(t *testing.T) {
+	s, err := NewSmoother("", 0, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, s)
+
+	s, err = NewSmoother("ewma", 0.3, 0)
+	assert.Nil(t, err)
+	assert.IsType(t, &EWMA{}, s)
+
+	s, err = NewSmoother("median", 0, 5)
+	assert.Nil(t, err)
+	assert.IsType(t, &MovingMedian{}, s)
+
+	_, err = NewSmoother("ewma", 1.5, 0)
+	assert.NotNil(t, err)
+	_, err = NewSmoother("median", 0, 0)
+	assert.NotNil(t, err)
+	_, err = NewSmoother("kalman", 0, 0)
+	assert.NotNil(t, err)
+}
+
+func TestConfirmation(t *testing.T) {
+	c := &Confirmation{Polls: 3}
+
+	assert.False(t, c.Confirm(Up))
+	assert.False(t, c.Confirm(Up))
+	assert.True(t, c.Confirm(Up))
+	assert.True(t, c.Confirm(Up))
+
+	// A change of direction starts counting again
+	assert.False(t, c.Confirm(Down))
+	assert.True(t, c.Confirm(Hold))
+	assert.False(t, c.Confirm(Down))
+	assert.False(t, c.Confirm(Down))
+	assert.True(t, c.Confirm(Down))
+}
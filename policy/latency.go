@@ -0,0 +1,27 @@
+package policy
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// LatencySLO scales up whenever the oldest message in the queue has waited
+// longer than SLO, regardless of how many messages are queued.
+type LatencySLO struct {
+	SLO time.Duration
+	Log *log.Entry
+}
+
+func (l LatencySLO) Direction(ageOfOldestMessage time.Duration) Direction {
+	if ageOfOldestMessage <= l.SLO {
+		return Hold
+	}
+
+	logger(l.Log).WithFields(log.Fields{
+		"ageOfOldestMessage": ageOfOldestMessage,
+		"slo":                l.SLO,
+	}).Warn("Oldest message exceeds latency SLO")
+
+	return Up
+}
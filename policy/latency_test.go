@@ -0,0 +1,16 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencySLO(t *testing.T) {
+	l := LatencySLO{SLO: 2 * time.Minute}
+
+	assert.Equal(t, Hold, l.Direction(0))
+	assert.Equal(t, Hold, l.Direction(2*time.Minute))
+	assert.Equal(t, Up, l.Direction(2*time.Minute+time.Second))
+}
@@ -0,0 +1,15 @@
+package policy
+
+import (
+	log "github.com/Sirupsen/logrus"
+)
+
+// logger returns l, or an entry on the standard logger if l is nil. Policies
+// take an optional Log so that a process scaling several targets can tell
+// their log lines apart.
+func logger(l *log.Entry) *log.Entry {
+	if l == nil {
+		return log.NewEntry(log.StandardLogger())
+	}
+	return l
+}
@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"math"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// PID is a proportional-integral-derivative controller that drives the queue
+// depth towards Setpoint. Its output is the desired number of replicas,
+// clamped to Min and Max.
+type PID struct {
+	Setpoint float64
+	Kp       float64
+	Ki       float64
+	Kd       float64
+
+	// IntegralLimit bounds the magnitude of the accumulated integral. Zero
+	// means unbounded. Independent of it, the integral does not accumulate
+	// while the output is saturated at Min or Max.
+	IntegralLimit float64
+
+	Min int
+	Max int
+
+	Log *log.Entry
+
+	integral  float64
+	lastError float64
+	lastTime  time.Time
+}
+
+func NewPID(setpoint, kp, ki, kd, integralLimit float64, min int, max int) *PID {
+	return &PID{
+		Setpoint:      setpoint,
+		Kp:            kp,
+		Ki:            ki,
+		Kd:            kd,
+		IntegralLimit: integralLimit,
+		Min:           min,
+		Max:           max,
+	}
+}
+
+// DesiredReplicas updates the controller with a new queue depth sample taken
+// at now and returns the number of replicas it recommends.
+func (c *PID) DesiredReplicas(numMessages int, now time.Time) int {
+	e := float64(numMessages) - c.Setpoint
+
+	var derivative float64
+	integral := c.integral
+	if !c.lastTime.IsZero() {
+		dt := now.Sub(c.lastTime).Seconds()
+		if dt > 0 {
+			integral += e * dt
+			derivative = (e - c.lastError) / dt
+		}
+	}
+
+	if c.IntegralLimit > 0 {
+		integral = math.Max(-c.IntegralLimit, math.Min(c.IntegralLimit, integral))
+	}
+
+	// While the output is saturated at Min or Max, errors that push it
+	// further out are not integrated, so that the integral does not wind up
+	// during long idle periods or bursts and hold the replicas at a limit
+	// long after
+	held := math.Ceil(c.Kp*e + c.Ki*c.integral + c.Kd*derivative)
+	if !(held > float64(c.Max) && e > 0) && !(held < float64(c.Min) && e < 0) {
+		c.integral = integral
+	}
+
+	output := c.Kp*e + c.Ki*c.integral + c.Kd*derivative
+
+	desired := int(math.Ceil(output))
+	if desired > c.Max {
+		desired = c.Max
+	}
+	if desired < c.Min {
+		desired = c.Min
+	}
+
+	logger(c.Log).WithFields(log.Fields{
+		"setpoint":   c.Setpoint,
+		"error":      e,
+		"integral":   c.integral,
+		"derivative": derivative,
+		"lastError":  c.lastError,
+		"output":     output,
+		"desired":    desired,
+	}).Info("PID controller state")
+
+	c.lastError = e
+	c.lastTime = now
+
+	return desired
+}
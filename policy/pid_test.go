@@ -0,0 +1,72 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPIDProportional(t *testing.T) {
+	c := NewPID(100, 0.1, 0, 0, 0, 1, 20)
+	now := time.Now()
+
+	assert.Equal(t, 10, c.DesiredReplicas(200, now))
+	assert.Equal(t, 20, c.DesiredReplicas(1000, now.Add(time.Second)))
+	assert.Equal(t, 1, c.DesiredReplicas(50, now.Add(2*time.Second)))
+}
+
+func TestPIDIntegral(t *testing.T) {
+	c := NewPID(0, 0, 0.01, 0, 0, 1, 20)
+	now := time.Now()
+
+	// The first sample has no elapsed time so nothing is integrated
+	assert.Equal(t, 1, c.DesiredReplicas(100, now))
+
+	// 100 messages for 10 seconds accumulates an integral of 1000
+	assert.Equal(t, 10, c.DesiredReplicas(100, now.Add(10*time.Second)))
+	assert.Equal(t, 20, c.DesiredReplicas(100, now.Add(20*time.Second)))
+}
+
+func TestPIDIntegralWindup(t *testing.T) {
+	c := NewPID(100, 0, 0.01, 0, 500, 1, 20)
+	now := time.Now()
+
+	c.DesiredReplicas(1100, now)
+	assert.Equal(t, 5, c.DesiredReplicas(1100, now.Add(time.Minute)))
+	assert.Equal(t, 500.0, c.integral)
+
+	// A bounded integral unwinds as soon as the queue drains below the setpoint
+	assert.Equal(t, 1, c.DesiredReplicas(0, now.Add(2*time.Minute)))
+	assert.Equal(t, -500.0, c.integral)
+}
+
+func TestPIDIdleBurstIdle(t *testing.T) {
+	// The default flags
+	c := NewPID(100, 0.01, 0.0001, 0, 100000, 1, 40)
+	now := time.Now()
+	poll := func(messages int, d time.Duration) int {
+		var desired int
+		for end := now.Add(d); now.Before(end); now = now.Add(30 * time.Second) {
+			desired = c.DesiredReplicas(messages, now)
+		}
+		return desired
+	}
+
+	assert.Equal(t, 1, poll(0, 8*time.Hour))
+	assert.Equal(t, 0.0, c.integral, "An idle queue should not wind the integral up")
+
+	assert.Equal(t, 40, poll(10000, 30*time.Second), "A burst after a long idle period should scale up at once")
+	assert.Equal(t, 40, poll(10000, 8*time.Hour))
+	assert.Equal(t, 0.0, c.integral, "A saturated burst should not wind the integral up")
+
+	assert.Equal(t, 1, poll(0, 30*time.Second), "An empty queue after a long burst should scale down at once")
+}
+
+func TestPIDDerivative(t *testing.T) {
+	c := NewPID(0, 0, 0, 1, 0, 1, 20)
+	now := time.Now()
+
+	c.DesiredReplicas(100, now)
+	assert.Equal(t, 10, c.DesiredReplicas(200, now.Add(10*time.Second)))
+}
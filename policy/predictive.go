@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"math"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Sample is a queue depth observed at a point in time.
+type Sample struct {
+	Time     time.Time
+	Messages float64
+}
+
+// Forecaster predicts the queue depth at a future time from a history of
+// samples ordered from oldest to newest.
+type Forecaster interface {
+	Forecast(history []Sample, at time.Time) float64
+}
+
+// LinearTrend forecasts by fitting a least squares line through the history.
+type LinearTrend struct{}
+
+func (LinearTrend) Forecast(history []Sample, at time.Time) float64 {
+	if len(history) == 0 {
+		return 0
+	}
+
+	slope, intercept := fit(history)
+	return intercept + slope*at.Sub(history[0].Time).Seconds()
+}
+
+// fit returns the slope per second and the intercept at the first sample of
+// the least squares line through the history.
+func fit(history []Sample) (slope float64, intercept float64) {
+	origin := history[0].Time
+	n := float64(len(history))
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range history {
+		x := s.Time.Sub(origin).Seconds()
+		sumX += x
+		sumY += s.Messages
+		sumXY += x * s.Messages
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, sumY / n
+	}
+
+	slope = (n*sumXY - sumX*sumY) / denominator
+	intercept = (sumY - slope*sumX) / n
+
+	return slope, intercept
+}
+
+// HoltWinters forecasts with additive triple exponential smoothing. Samples
+// are assumed to be evenly spaced, and SeasonLength is the number of samples
+// in one season. Until two full seasons of history are available it falls
+// back to a linear trend.
+type HoltWinters struct {
+	Alpha        float64
+	Beta         float64
+	Gamma        float64
+	SeasonLength int
+}
+
+func (h HoltWinters) Forecast(history []Sample, at time.Time) float64 {
+	L := h.SeasonLength
+	n := len(history)
+
+	if L < 1 || n < 2*L {
+		return LinearTrend{}.Forecast(history, at)
+	}
+
+	var first, second float64
+	for i := 0; i < L; i++ {
+		first += history[i].Messages
+		second += history[L+i].Messages
+	}
+	first /= float64(L)
+	second /= float64(L)
+
+	level := first
+	trend := (second - first) / float64(L)
+
+	seasonals := make([]float64, L)
+	for i := 0; i < L; i++ {
+		seasonals[i] = history[i].Messages - first
+	}
+
+	for i, s := range history {
+		lastLevel := level
+		level = h.Alpha*(s.Messages-seasonals[i%L]) + (1-h.Alpha)*(level+trend)
+		trend = h.Beta*(level-lastLevel) + (1-h.Beta)*trend
+		seasonals[i%L] = h.Gamma*(s.Messages-level) + (1-h.Gamma)*seasonals[i%L]
+	}
+
+	interval := history[n-1].Time.Sub(history[0].Time).Seconds() / float64(n-1)
+	steps := 0
+	if interval > 0 {
+		steps = int(math.Ceil(at.Sub(history[n-1].Time).Seconds() / interval))
+	}
+
+	return level + float64(steps)*trend + seasonals[(n-1+steps)%L]
+}
+
+// Predictive keeps a rolling history of queue depths and forecasts the depth
+// Horizon into the future, typically the time a new pod needs to become
+// useful.
+type Predictive struct {
+	Forecaster Forecaster
+	Horizon    time.Duration
+	// Size is the maximum number of samples kept in the history.
+	Size int
+
+	Log *log.Entry
+
+	history   []Sample
+	forecasts []Sample
+}
+
+func NewPredictive(forecaster Forecaster, horizon time.Duration, size int) *Predictive {
+	return &Predictive{
+		Forecaster: forecaster,
+		Horizon:    horizon,
+		Size:       size,
+	}
+}
+
+// Observe records a queue depth sample taken at now and returns the forecast
+// queue depth at now plus Horizon. Negative forecasts are reported as zero.
+func (p *Predictive) Observe(numMessages int, now time.Time) float64 {
+	p.history = append(p.history, Sample{Time: now, Messages: float64(numMessages)})
+	if len(p.history) > p.Size {
+		p.history = p.history[len(p.history)-p.Size:]
+	}
+
+	forecast := math.Max(0, p.Forecaster.Forecast(p.history, now.Add(p.Horizon)))
+	p.forecasts = append(p.forecasts, Sample{Time: now.Add(p.Horizon), Messages: forecast})
+
+	fields := log.Fields{
+		"actual":   numMessages,
+		"forecast": forecast,
+		"horizon":  p.Horizon,
+	}
+
+	// Report the forecast that was made for this moment next to the actual
+	// depth so the accuracy of the model can be judged.
+	due := -1
+	for i, f := range p.forecasts {
+		if f.Time.After(now) {
+			break
+		}
+		due = i
+	}
+	if due >= 0 {
+		fields["forecastForNow"] = p.forecasts[due].Messages
+		p.forecasts = p.forecasts[due+1:]
+	}
+
+	logger(p.Log).WithFields(fields).Info("Queue depth forecast")
+
+	return forecast
+}
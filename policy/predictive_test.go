@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func samples(start time.Time, interval time.Duration, values ...float64) []Sample {
+	var history []Sample
+	for i, v := range values {
+		history = append(history, Sample{Time: start.Add(time.Duration(i) * interval), Messages: v})
+	}
+	return history
+}
+
+func TestLinearTrend(t *testing.T) {
+	start := time.Now()
+	history := samples(start, time.Second, 0, 10, 20, 30)
+
+	assert.InDelta(t, 100, LinearTrend{}.Forecast(history, start.Add(10*time.Second)), 0.001)
+
+	// A single sample forecasts a flat line
+	assert.InDelta(t, 10, LinearTrend{}.Forecast(history[1:2], start.Add(time.Minute)), 0.001)
+}
+
+func TestHoltWintersSeasonal(t *testing.T) {
+	start := time.Now()
+	h := HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.5, SeasonLength: 4}
+	history := samples(start, time.Second, 0, 100, 0, 100, 0, 100, 0, 100, 0, 100, 0, 100)
+
+	// The last sample is at index 11, a peak, so one step ahead is a trough
+	// and two steps ahead is another peak.
+	assert.InDelta(t, 0, h.Forecast(history, start.Add(12*time.Second)), 5)
+	assert.InDelta(t, 100, h.Forecast(history, start.Add(13*time.Second)), 5)
+}
+
+func TestHoltWintersFallsBackToLinear(t *testing.T) {
+	start := time.Now()
+	h := HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.5, SeasonLength: 4}
+	history := samples(start, time.Second, 0, 10, 20, 30)
+
+	assert.InDelta(t, 100, h.Forecast(history, start.Add(10*time.Second)), 0.001)
+}
+
+func TestPredictiveObserve(t *testing.T) {
+	start := time.Now()
+	p := NewPredictive(LinearTrend{}, 10*time.Second, 3)
+
+	p.Observe(0, start)
+	p.Observe(10, start.Add(time.Second))
+	assert.InDelta(t, 120, p.Observe(20, start.Add(2*time.Second)), 0.001)
+
+	// Old samples fall out of the history
+	p.Observe(0, start.Add(3*time.Second))
+	assert.Len(t, p.history, 3)
+
+	// Forecasts are never negative
+	p.Observe(0, start.Add(4*time.Second))
+	assert.Equal(t, 0.0, p.Observe(0, start.Add(5*time.Second)))
+}
@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Direction is a scaling decision.
+type Direction int
+
+const (
+	Hold Direction = iota
+	Up
+	Down
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Up:
+		return "up"
+	case Down:
+		return "down"
+	}
+	return "hold"
+}
+
+// RatePolicy scales on how the backlog is moving rather than on a single
+// snapshot. SQS only reports the queue depth, so the rate is the net flow:
+// arrivals minus consumption, in messages per second, fitted over Window.
+// A positive rate means the queue is growing and a negative rate is the
+// speed at which consumers are draining it.
+type RatePolicy struct {
+	ScaleUpMessages   int
+	ScaleDownMessages int
+	// DrainTime is how quickly the backlog must be drained at the current
+	// rate to consider the extra capacity unnecessary.
+	DrainTime time.Duration
+	Window    time.Duration
+
+	Log *log.Entry
+
+	samples []Sample
+}
+
+func NewRatePolicy(scaleUpMessages int, scaleDownMessages int, drainTime time.Duration, window time.Duration) *RatePolicy {
+	return &RatePolicy{
+		ScaleUpMessages:   scaleUpMessages,
+		ScaleDownMessages: scaleDownMessages,
+		DrainTime:         drainTime,
+		Window:            window,
+	}
+}
+
+// Rate records a sample and returns the net inflow in messages per second,
+// and false while there are not yet enough samples to tell.
+func (p *RatePolicy) Rate(numMessages int, now time.Time) (float64, bool) {
+	p.samples = append(p.samples, Sample{Time: now, Messages: float64(numMessages)})
+
+	cutoff := now.Add(-p.Window)
+	for len(p.samples) > 2 && p.samples[0].Time.Before(cutoff) {
+		p.samples = p.samples[1:]
+	}
+
+	if len(p.samples) < 2 {
+		return 0, false
+	}
+
+	slope, _ := fit(p.samples)
+	return slope, true
+}
+
+// Direction returns the scaling decision for a new queue depth sample. It
+// scales up only while the backlog is above the threshold and still growing,
+// and scales down once the backlog is small and not growing, or is being
+// drained faster than DrainTime requires.
+func (p *RatePolicy) Direction(numMessages int, now time.Time) Direction {
+	rate, ok := p.Rate(numMessages, now)
+	if !ok {
+		return Hold
+	}
+
+	direction := Hold
+	drainTime := time.Duration(0)
+
+	switch {
+	case numMessages >= p.ScaleUpMessages && rate > 0:
+		direction = Up
+	case numMessages <= p.ScaleDownMessages && rate <= 0:
+		direction = Down
+	case rate < 0:
+		drainTime = time.Duration(float64(numMessages) / -rate * float64(time.Second))
+		if drainTime < p.DrainTime {
+			direction = Down
+		}
+	}
+
+	logger(p.Log).WithFields(log.Fields{
+		"messages":  numMessages,
+		"netInflow": rate,
+		"drainTime": drainTime,
+		"direction": direction,
+	}).Info("Queue rate")
+
+	return direction
+}
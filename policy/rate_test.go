@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatePolicyNeedsTwoSamples(t *testing.T) {
+	p := NewRatePolicy(100, 10, 5*time.Minute, time.Minute)
+
+	assert.Equal(t, Hold, p.Direction(10000, time.Now()))
+}
+
+func TestRatePolicyGrowingBacklog(t *testing.T) {
+	p := NewRatePolicy(100, 10, 5*time.Minute, time.Minute)
+	now := time.Now()
+
+	p.Direction(1000, now)
+	assert.Equal(t, Up, p.Direction(1100, now.Add(10*time.Second)))
+}
+
+func TestRatePolicyDrainingBacklog(t *testing.T) {
+	p := NewRatePolicy(100, 10, 5*time.Minute, time.Minute)
+	now := time.Now()
+
+	// Draining 10 messages a second leaves 990 seconds of backlog, so the
+	// current capacity is needed but no more should be added.
+	p.Direction(10000, now)
+	assert.Equal(t, Hold, p.Direction(9900, now.Add(10*time.Second)))
+
+	// Draining 100 messages a second empties the queue within two minutes
+	p = NewRatePolicy(100, 10, 5*time.Minute, time.Minute)
+	p.Direction(10000, now)
+	assert.Equal(t, Down, p.Direction(9000, now.Add(10*time.Second)))
+}
+
+func TestRatePolicySmallBacklog(t *testing.T) {
+	p := NewRatePolicy(100, 10, 5*time.Minute, time.Minute)
+	now := time.Now()
+
+	p.Direction(5, now)
+	assert.Equal(t, Down, p.Direction(5, now.Add(10*time.Second)))
+
+	// A small but growing backlog is left alone
+	assert.Equal(t, Hold, p.Direction(9, now.Add(20*time.Second)))
+}
+
+func TestRatePolicyWindow(t *testing.T) {
+	p := NewRatePolicy(100, 10, 5*time.Minute, time.Minute)
+	now := time.Now()
+
+	p.Direction(0, now)
+	p.Direction(5000, now.Add(10*time.Second))
+	p.Direction(5000, now.Add(2*time.Minute))
+	p.Direction(5000, now.Add(3*time.Minute))
+
+	rate, ok := p.Rate(5000, now.Add(3*time.Minute+10*time.Second))
+	assert.True(t, ok)
+	assert.InDelta(t, 0, rate, 0.001)
+}
@@ -0,0 +1,53 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ReadinessWait holds scale ups back while pods added earlier are not
+// available yet, so that pods that are still pending or starting are not
+// piled on with more. It gives up waiting once the gap between the desired
+// and the available replicas has been open for Timeout, and waits again once
+// the gap has closed.
+type ReadinessWait struct {
+	Timeout time.Duration
+
+	Log *log.Entry
+
+	gapSince time.Time
+	timedOut bool
+}
+
+func NewReadinessWait(timeout time.Duration) *ReadinessWait {
+	return &ReadinessWait{
+		Timeout: timeout,
+	}
+}
+
+// Wait returns true if a scale up should wait for the available replicas to
+// catch up with the desired replicas, and the reason it waits.
+func (r *ReadinessWait) Wait(desired int, available int, now time.Time) (bool, string) {
+	if available >= desired {
+		r.gapSince = time.Time{}
+		r.timedOut = false
+		return false, ""
+	}
+
+	if r.gapSince.IsZero() {
+		r.gapSince = now
+	}
+
+	waited := now.Sub(r.gapSince)
+	if waited >= r.Timeout {
+		if !r.timedOut {
+			logger(r.Log).Warnf("%d of %d replicas still not available after %s, no longer waiting for them", available, desired, r.Timeout)
+			r.timedOut = true
+		}
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("%d of %d replicas available, waited %s of %s", available, desired, waited-waited%time.Second, r.Timeout)
+}
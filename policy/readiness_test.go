@@ -0,0 +1,34 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessWait(t *testing.T) {
+	r := NewReadinessWait(time.Minute)
+	now := time.Now()
+
+	wait, _ := r.Wait(3, 3, now)
+	assert.False(t, wait, "All replicas are available")
+
+	wait, reason := r.Wait(5, 3, now)
+	assert.True(t, wait)
+	assert.Equal(t, "3 of 5 replicas available, waited 0s of 1m0s", reason)
+
+	wait, reason = r.Wait(5, 4, now.Add(30*time.Second))
+	assert.True(t, wait)
+	assert.Equal(t, "4 of 5 replicas available, waited 30s of 1m0s", reason)
+
+	wait, _ = r.Wait(5, 4, now.Add(time.Minute))
+	assert.False(t, wait, "Waiting should time out")
+	wait, _ = r.Wait(8, 4, now.Add(2*time.Minute))
+	assert.False(t, wait, "Scale ups should go ahead until the gap closes")
+
+	wait, _ = r.Wait(8, 8, now.Add(3*time.Minute))
+	assert.False(t, wait)
+	wait, _ = r.Wait(10, 8, now.Add(3*time.Minute))
+	assert.True(t, wait, "Waiting should start again once the gap closed")
+}
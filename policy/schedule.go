@@ -0,0 +1,349 @@
+package policy
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Cron is a cron expression with the five standard fields: minute, hour,
+// day of month, month and day of week. A time matches when every field
+// matches, except that when both day fields are restricted either may match.
+type Cron struct {
+	expr   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	domStar bool
+	dowStar bool
+}
+
+type cronField struct {
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{min: 0, max: 59},
+	{min: 0, max: 23},
+	{min: 1, max: 31},
+	{min: 1, max: 12, names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// ParseCron parses a cron expression such as "* 8-19 * * mon-fri". Each field
+// is a comma separated list of *, values or ranges, optionally with a /step.
+func ParseCron(s string) (Cron, error) {
+	fields := strings.Fields(s)
+	if len(fields) != len(cronFields) {
+		return Cron{}, errors.Errorf("Invalid cron expression %q, expected 5 fields", s)
+	}
+
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := cronFields[i].parse(field)
+		if err != nil {
+			return Cron{}, errors.Wrapf(err, "Invalid cron expression %q", s)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return Cron{
+		expr:    strings.Join(fields, " "),
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, errors.Errorf("Invalid step in %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := f.min, f.max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = f.max
+			}
+
+			if hi < lo {
+				return 0, errors.Errorf("Invalid range %q", part)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errors.Errorf("Invalid value %q, expected %d-%d", s, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// Matches returns true if the minute of t is matched by the expression.
+func (c Cron) Matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 ||
+		c.hour&(1<<uint(t.Hour())) == 0 ||
+		c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c Cron) String() string {
+	return c.expr
+}
+
+// Limits are the settings of a target that a schedule can override.
+type Limits struct {
+	MinPods              int
+	MaxPods              int
+	ScaleUpMessages      int
+	ScaleDownMessages    int
+	TargetMessagesPerPod int
+}
+
+// Schedule overrides some of the limits of a target during every minute
+// matched by its cron expression in its time zone. Nil overrides keep the
+// value of the target.
+type Schedule struct {
+	Name     string
+	Cron     Cron
+	Location *time.Location
+
+	MinPods              *int
+	MaxPods              *int
+	ScaleUpMessages      *int
+	ScaleDownMessages    *int
+	TargetMessagesPerPod *int
+}
+
+// Active returns true if the schedule applies at now.
+func (s *Schedule) Active(now time.Time) bool {
+	location := s.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	return s.Cron.Matches(now.In(location))
+}
+
+// Apply returns the limits with the schedule's overrides.
+func (s *Schedule) Apply(l Limits) Limits {
+	override := func(v *int, dst *int) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+
+	override(s.MinPods, &l.MinPods)
+	override(s.MaxPods, &l.MaxPods)
+	override(s.ScaleUpMessages, &l.ScaleUpMessages)
+	override(s.ScaleDownMessages, &l.ScaleDownMessages)
+	override(s.TargetMessagesPerPod, &l.TargetMessagesPerPod)
+
+	return l
+}
+
+func (s *Schedule) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Cron.String()
+}
+
+// UnmarshalJSON reads a schedule in the form
+// {"name": "business-hours", "cron": "* 8-19 * * mon-fri",
+// "timezone": "America/Toronto", "minPods": 5, "maxPods": 50}.
+func (s *Schedule) UnmarshalJSON(data []byte) error {
+	var config struct {
+		Name                 string `json:"name"`
+		Cron                 string `json:"cron"`
+		Timezone             string `json:"timezone"`
+		MinPods              *int   `json:"minPods"`
+		MaxPods              *int   `json:"maxPods"`
+		ScaleUpMessages      *int   `json:"scaleUpMessages"`
+		ScaleDownMessages    *int   `json:"scaleDownMessages"`
+		TargetMessagesPerPod *int   `json:"targetMessagesPerPod"`
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+
+	cron, err := ParseCron(config.Cron)
+	if err != nil {
+		return err
+	}
+
+	location, err := loadLocation(config.Timezone)
+	if err != nil {
+		return err
+	}
+
+	*s = Schedule{
+		Name:                 config.Name,
+		Cron:                 cron,
+		Location:             location,
+		MinPods:              config.MinPods,
+		MaxPods:              config.MaxPods,
+		ScaleUpMessages:      config.ScaleUpMessages,
+		ScaleDownMessages:    config.ScaleDownMessages,
+		TargetMessagesPerPod: config.TargetMessagesPerPod,
+	}
+	return nil
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid timezone %q", name)
+	}
+
+	return location, nil
+}
+
+// Schedules is an ordered list of schedules. When several are active, the
+// first one wins.
+type Schedules []Schedule
+
+// Active returns the first schedule active at now, or nil if there is none.
+func (s Schedules) Active(now time.Time) *Schedule {
+	for i := range s {
+		if s[i].Active(now) {
+			return &s[i]
+		}
+	}
+
+	return nil
+}
+
+func (s *Schedules) String() string {
+	if s == nil {
+		return ""
+	}
+
+	var names []string
+	for i := range *s {
+		names = append(names, (*s)[i].String())
+	}
+	return strings.Join(names, ",")
+}
+
+// Set implements flag.Value, adding a schedule in the form
+// cron[;name=name][;timezone=zone][;min-pods=n][;max-pods=n]
+// [;scale-up-messages=n][;scale-down-messages=n][;target-messages-per-pod=n]
+// each time the flag is given.
+func (s *Schedules) Set(v string) error {
+	fields := strings.Split(v, ";")
+
+	cron, err := ParseCron(fields[0])
+	if err != nil {
+		return err
+	}
+
+	schedule := Schedule{Cron: cron, Location: time.UTC}
+
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("Invalid schedule option %q, expected key=value", field)
+		}
+
+		var dst **int
+
+		switch parts[0] {
+		case "name":
+			schedule.Name = parts[1]
+			continue
+		case "timezone":
+			if schedule.Location, err = loadLocation(parts[1]); err != nil {
+				return err
+			}
+			continue
+		case "min-pods":
+			dst = &schedule.MinPods
+		case "max-pods":
+			dst = &schedule.MaxPods
+		case "scale-up-messages":
+			dst = &schedule.ScaleUpMessages
+		case "scale-down-messages":
+			dst = &schedule.ScaleDownMessages
+		case "target-messages-per-pod":
+			dst = &schedule.TargetMessagesPerPod
+		default:
+			return errors.Errorf("Unknown schedule option %q", parts[0])
+		}
+
+		n, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return errors.Errorf("Invalid value for schedule option %q", field)
+		}
+		*dst = &n
+	}
+
+	*s = append(*s, schedule)
+	return nil
+}
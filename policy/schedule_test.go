@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"*/15 8-19 * * mon-fri",
+		"0,30 0 1 jan,jul 0",
+		"5/10 * 1-15 * 7",
+	} {
+		_, err := ParseCron(expr)
+		assert.Nil(t, err, expr)
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* 19-8 * * *",
+		"*/0 * * * *",
+		"* * * * funday",
+	} {
+		_, err := ParseCron(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	business, _ := ParseCron("* 8-19 * * mon-fri")
+
+	// 2017-03-06 is a Monday
+	assert.True(t, business.Matches(time.Date(2017, 3, 6, 8, 0, 0, 0, time.UTC)))
+	assert.True(t, business.Matches(time.Date(2017, 3, 10, 19, 59, 0, 0, time.UTC)))
+	assert.False(t, business.Matches(time.Date(2017, 3, 6, 20, 0, 0, 0, time.UTC)))
+	assert.False(t, business.Matches(time.Date(2017, 3, 6, 7, 59, 0, 0, time.UTC)))
+	assert.False(t, business.Matches(time.Date(2017, 3, 11, 12, 0, 0, 0, time.UTC)))
+
+	quarters, _ := ParseCron("*/15 * * * *")
+	assert.True(t, quarters.Matches(time.Date(2017, 3, 6, 8, 45, 0, 0, time.UTC)))
+	assert.False(t, quarters.Matches(time.Date(2017, 3, 6, 8, 46, 0, 0, time.UTC)))
+
+	// Sunday may be given as 7
+	sunday, _ := ParseCron("* * * * 7")
+	assert.True(t, sunday.Matches(time.Date(2017, 3, 12, 0, 0, 0, 0, time.UTC)))
+
+	// When both days are restricted, either one matches
+	days, _ := ParseCron("* * 1 * mon")
+	assert.True(t, days.Matches(time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, days.Matches(time.Date(2017, 3, 6, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, days.Matches(time.Date(2017, 3, 7, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestScheduleActiveInTimezone(t *testing.T) {
+	var schedules Schedules
+	assert.Nil(t, schedules.Set("* 8-19 * * mon-fri;name=business;timezone=America/Toronto;min-pods=5"))
+
+	// 13:00 UTC is 08:00 in Toronto during daylight saving time
+	assert.NotNil(t, schedules.Active(time.Date(2017, 7, 3, 13, 0, 0, 0, time.UTC)))
+	assert.Nil(t, schedules.Active(time.Date(2017, 7, 3, 11, 0, 0, 0, time.UTC)))
+	assert.Nil(t, schedules.Active(time.Date(2017, 7, 4, 0, 30, 0, 0, time.UTC)))
+}
+
+func TestSchedulesFirstActiveWins(t *testing.T) {
+	var schedules Schedules
+	assert.Nil(t, schedules.Set("* 8-19 * * *;name=day;max-pods=50"))
+	assert.Nil(t, schedules.Set("* * * * *;name=always;max-pods=5"))
+
+	assert.Equal(t, "day", schedules.Active(time.Date(2017, 3, 6, 12, 0, 0, 0, time.UTC)).Name)
+	assert.Equal(t, "always", schedules.Active(time.Date(2017, 3, 6, 22, 0, 0, 0, time.UTC)).Name)
+}
+
+func TestScheduleApply(t *testing.T) {
+	var schedules Schedules
+	assert.Nil(t, schedules.Set("* * * * *;min-pods=0;scale-up-messages=500;target-messages-per-pod=20"))
+
+	limits := Limits{MinPods: 1, MaxPods: 10, ScaleUpMessages: 100, ScaleDownMessages: 10}
+	assert.Equal(t, Limits{
+		MinPods:              0,
+		MaxPods:              10,
+		ScaleUpMessages:      500,
+		ScaleDownMessages:    10,
+		TargetMessagesPerPod: 20,
+	}, schedules[0].Apply(limits))
+}
+
+func TestSchedulesSetInvalid(t *testing.T) {
+	var schedules Schedules
+
+	assert.NotNil(t, schedules.Set("* * *"))
+	assert.NotNil(t, schedules.Set("* * * * *;min-pods"))
+	assert.NotNil(t, schedules.Set("* * * * *;min-pods=few"))
+	assert.NotNil(t, schedules.Set("* * * * *;timezone=Nowhere/Special"))
+	assert.NotNil(t, schedules.Set("* * * * *;replicas=3"))
+	assert.Len(t, schedules, 0)
+}
+
+func TestScheduleUnmarshalJSON(t *testing.T) {
+	var schedules Schedules
+	err := json.Unmarshal([]byte(`[{
+		"name": "nights",
+		"cron": "* 0-7,20-23 * * *",
+		"timezone": "America/Toronto",
+		"maxPods": 2
+	}]`), &schedules)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "nights", schedules[0].String())
+	assert.Equal(t, "America/Toronto", schedules[0].Location.String())
+	assert.Nil(t, schedules[0].MinPods)
+	assert.Equal(t, 2, *schedules[0].MaxPods)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`[{"cron": "* * *"}]`), &schedules))
+	assert.NotNil(t, json.Unmarshal([]byte(`[{"cron": "* * * * *", "timezone": "Mars/Olympus"}]`), &schedules))
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+type recommendation struct {
+	time     time.Time
+	replicas int
+}
+
+// Stabilizer smooths replica recommendations the way the HPA's scaling
+// behavior does. Before scaling down it takes the highest recommendation
+// within ScaleDownWindow, and before scaling up the lowest recommendation
+// within ScaleUpWindow, so a flapping metric doesn't flap the deployment.
+type Stabilizer struct {
+	ScaleUpWindow   time.Duration
+	ScaleDownWindow time.Duration
+
+	Log *log.Entry
+
+	recommendations []recommendation
+}
+
+func NewStabilizer(scaleUpWindow time.Duration, scaleDownWindow time.Duration) *Stabilizer {
+	return &Stabilizer{
+		ScaleUpWindow:   scaleUpWindow,
+		ScaleDownWindow: scaleDownWindow,
+	}
+}
+
+// Stabilize records the recommended replica count at now and returns the
+// stabilized count to scale to from current.
+func (s *Stabilizer) Stabilize(recommended int, current int, now time.Time) int {
+	s.recommendations = append(s.recommendations, recommendation{time: now, replicas: recommended})
+
+	window := s.ScaleUpWindow
+	if s.ScaleDownWindow > window {
+		window = s.ScaleDownWindow
+	}
+	for len(s.recommendations) > 0 && now.Sub(s.recommendations[0].time) > window {
+		s.recommendations = s.recommendations[1:]
+	}
+
+	up := recommended
+	down := recommended
+	for _, r := range s.recommendations {
+		age := now.Sub(r.time)
+		if age <= s.ScaleUpWindow && r.replicas < up {
+			up = r.replicas
+		}
+		if age <= s.ScaleDownWindow && r.replicas > down {
+			down = r.replicas
+		}
+	}
+
+	stabilized := current
+	if stabilized < up {
+		stabilized = up
+	}
+	if stabilized > down {
+		stabilized = down
+	}
+
+	if stabilized != recommended {
+		logger(s.Log).WithFields(log.Fields{
+			"recommended": recommended,
+			"stabilized":  stabilized,
+			"current":     current,
+		}).Info("Stabilizing replica recommendation")
+	}
+
+	return stabilized
+}
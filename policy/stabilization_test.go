@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStabilizerScaleDown(t *testing.T) {
+	s := NewStabilizer(0, time.Minute)
+	now := time.Now()
+
+	assert.Equal(t, 5, s.Stabilize(5, 3, now))
+
+	// Lower recommendations within the window are held at the highest one
+	assert.Equal(t, 5, s.Stabilize(2, 5, now.Add(20*time.Second)))
+	assert.Equal(t, 5, s.Stabilize(3, 5, now.Add(40*time.Second)))
+
+	// Once the peak leaves the window the deployment may scale down to the
+	// highest remaining recommendation
+	assert.Equal(t, 3, s.Stabilize(2, 5, now.Add(70*time.Second)))
+	assert.Equal(t, 2, s.Stabilize(2, 3, now.Add(2*time.Minute)))
+}
+
+func TestStabilizerScaleUp(t *testing.T) {
+	s := NewStabilizer(time.Minute, 0)
+	now := time.Now()
+
+	assert.Equal(t, 3, s.Stabilize(3, 3, now))
+	assert.Equal(t, 3, s.Stabilize(8, 3, now.Add(20*time.Second)))
+	assert.Equal(t, 6, s.Stabilize(6, 3, now.Add(90*time.Second)))
+
+	// Scale downs are not delayed without a scale down window
+	assert.Equal(t, 1, s.Stabilize(1, 6, now.Add(100*time.Second)))
+}
+
+func TestStabilizerHold(t *testing.T) {
+	s := NewStabilizer(time.Minute, time.Minute)
+	now := time.Now()
+
+	s.Stabilize(2, 4, now)
+	s.Stabilize(6, 4, now.Add(10*time.Second))
+
+	// Recommendations on both sides of current keep it where it is
+	assert.Equal(t, 4, s.Stabilize(4, 4, now.Add(20*time.Second)))
+}
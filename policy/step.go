@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Wattpad/kube-sqs-autoscaler/scale"
+)
+
+// Step is a band of queue depths and the adjustment to apply while the number
+// of messages falls inside it. Lower is inclusive and Upper is exclusive; a
+// negative Upper means the band is unbounded.
+type Step struct {
+	Lower      int
+	Upper      int
+	Adjustment scale.Adjustment
+}
+
+func (s Step) Contains(numMessages int) bool {
+	return numMessages >= s.Lower && (s.Upper < 0 || numMessages < s.Upper)
+}
+
+// StepPolicy is an ordered list of steps. The first step containing the
+// queue depth decides the adjustment.
+type StepPolicy []Step
+
+// Adjustment returns the adjustment for the given queue depth, and false if
+// no step matches.
+func (p StepPolicy) Adjustment(numMessages int) (scale.Adjustment, bool) {
+	for _, step := range p {
+		if step.Contains(numMessages) {
+			return step.Adjustment, true
+		}
+	}
+
+	return scale.Adjustment{}, false
+}
+
+func (p *StepPolicy) String() string {
+	if p == nil {
+		return ""
+	}
+
+	var fields []string
+	for _, step := range *p {
+		upper := ""
+		if step.Upper >= 0 {
+			upper = strconv.Itoa(step.Upper)
+		}
+		fields = append(fields, fmt.Sprintf("%d:%s:%s", step.Lower, upper, step.Adjustment))
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// Set implements flag.Value.
+func (p *StepPolicy) Set(s string) error {
+	steps, err := ParseSteps(s)
+	if err != nil {
+		return err
+	}
+
+	*p = steps
+	return nil
+}
+
+// UnmarshalJSON accepts steps in the same form as the flag.
+func (p *StepPolicy) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return p.Set(s)
+}
+
+// ParseSteps parses a comma separated list of steps in the form
+// lower:upper:adjustment, e.g. "0:10:-1,100:1000:+1,1000::+50%". An empty
+// upper bound leaves the step unbounded.
+func ParseSteps(s string) (StepPolicy, error) {
+	var steps StepPolicy
+
+	if strings.TrimSpace(s) == "" {
+		return steps, nil
+	}
+
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(field), ":")
+		if len(parts) != 3 {
+			return nil, errors.Errorf("Invalid step %q, expected lower:upper:adjustment", field)
+		}
+
+		lower, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid lower bound in step %q", field)
+		}
+
+		upper := -1
+		if parts[1] != "" {
+			upper, err = strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid upper bound in step %q", field)
+			}
+			if upper <= lower {
+				return nil, errors.Errorf("Upper bound must be greater than lower bound in step %q", field)
+			}
+		}
+
+		adjustment, err := ParseAdjustment(parts[2])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid adjustment in step %q", field)
+		}
+
+		steps = append(steps, Step{
+			Lower:      lower,
+			Upper:      upper,
+			Adjustment: adjustment,
+		})
+	}
+
+	return steps, nil
+}
+
+// ParseAdjustment parses a relative change such as "+1", "-2" or "+50%".
+func ParseAdjustment(s string) (scale.Adjustment, error) {
+	percent := strings.HasSuffix(s, "%")
+
+	value, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+	if err != nil {
+		return scale.Adjustment{}, err
+	}
+
+	return scale.Adjustment{Value: value, Percent: percent}, nil
+}
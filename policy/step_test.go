@@ -0,0 +1,58 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Wattpad/kube-sqs-autoscaler/scale"
+)
+
+func TestParseSteps(t *testing.T) {
+	steps, err := ParseSteps("0:10:-1, 100:1000:+1,1000::+50%")
+	assert.Nil(t, err)
+	assert.Equal(t, StepPolicy{
+		{Lower: 0, Upper: 10, Adjustment: scale.Adjustment{Value: -1}},
+		{Lower: 100, Upper: 1000, Adjustment: scale.Adjustment{Value: 1}},
+		{Lower: 1000, Upper: -1, Adjustment: scale.Adjustment{Value: 50, Percent: true}},
+	}, steps)
+
+	steps, err = ParseSteps("")
+	assert.Nil(t, err)
+	assert.Len(t, steps, 0)
+
+	_, err = ParseSteps("0:10")
+	assert.NotNil(t, err)
+
+	_, err = ParseSteps("10:5:+1")
+	assert.NotNil(t, err)
+
+	_, err = ParseSteps("0:10:one")
+	assert.NotNil(t, err)
+}
+
+func TestStepPolicyAdjustment(t *testing.T) {
+	steps, _ := ParseSteps("0:10:-2,100:1000:+1,1000::+5")
+
+	a, ok := steps.Adjustment(5)
+	assert.True(t, ok)
+	assert.Equal(t, -2, a.Value)
+
+	a, ok = steps.Adjustment(1000)
+	assert.True(t, ok)
+	assert.Equal(t, 5, a.Value)
+
+	_, ok = steps.Adjustment(50)
+	assert.False(t, ok)
+}
+
+func TestStepPolicyFlag(t *testing.T) {
+	var steps StepPolicy
+
+	err := steps.Set("0:10:-1,1000::+50%")
+	assert.Nil(t, err)
+	assert.Equal(t, "0:10:-1,1000::+50%", steps.String())
+
+	err = steps.Set("bad")
+	assert.NotNil(t, err)
+}
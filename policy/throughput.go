@@ -0,0 +1,135 @@
+package policy
+
+import (
+	"math"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// minThroughputSamples is the number of samples needed before the throughput
+// is estimated from them.
+const minThroughputSamples = 3
+
+// Throughput learns how many messages per second one pod drains by
+// correlating the rate of change of the queue depth with the number of
+// replicas. Assuming a steady inflow, the depth rate is a linear function
+// inflow - throughput * replicas, so the throughput is the negated slope of a
+// least squares fit over the samples in Window. Samples where the queue ran
+// empty are skipped, since the pods were not draining at full speed.
+type Throughput struct {
+	Window time.Duration
+	// Smoothing is the weight of each new estimate in the learned
+	// throughput, between 0 and 1.
+	Smoothing float64
+	// PerPod is the learned throughput in messages per second per pod, or
+	// zero until enough samples have been observed.
+	PerPod float64
+
+	Log *log.Entry
+
+	samples  []throughputSample
+	last     time.Time
+	lastSize int
+	lastPods int
+}
+
+type throughputSample struct {
+	time     time.Time
+	replicas float64
+	rate     float64
+}
+
+// NewThroughput returns a throughput estimator that starts from initial
+// messages per second per pod, e.g. a previously learned value.
+func NewThroughput(window time.Duration, smoothing float64, initial float64) *Throughput {
+	return &Throughput{
+		Window:    window,
+		Smoothing: smoothing,
+		PerPod:    initial,
+	}
+}
+
+// Observe records the queue depth with the replicas that were consuming it,
+// and returns true if the learned throughput changed.
+func (t *Throughput) Observe(numMessages int, replicas int, now time.Time) bool {
+	last, lastSize, lastPods := t.last, t.lastSize, t.lastPods
+	t.last, t.lastSize, t.lastPods = now, numMessages, replicas
+
+	if last.IsZero() || lastSize == 0 || numMessages == 0 || !now.After(last) {
+		return false
+	}
+
+	t.samples = append(t.samples, throughputSample{
+		time:     now,
+		replicas: float64(lastPods),
+		rate:     float64(numMessages-lastSize) / now.Sub(last).Seconds(),
+	})
+
+	for len(t.samples) > 0 && now.Sub(t.samples[0].time) > t.Window {
+		t.samples = t.samples[1:]
+	}
+
+	estimate, ok := t.estimate()
+	if !ok {
+		return false
+	}
+
+	if t.PerPod == 0 {
+		t.PerPod = estimate
+	} else {
+		t.PerPod = t.Smoothing*estimate + (1-t.Smoothing)*t.PerPod
+	}
+
+	logger(t.Log).Infof("Learned throughput: %.3f messages/s per pod", t.PerPod)
+	return true
+}
+
+// estimate fits the depth rate against the replicas and returns the
+// throughput it implies, if the samples cover more than one replica count and
+// more pods drain the queue faster.
+func (t *Throughput) estimate() (float64, bool) {
+	if len(t.samples) < minThroughputSamples {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+	for _, s := range t.samples {
+		meanX += s.replicas
+		meanY += s.rate
+	}
+	meanX /= float64(len(t.samples))
+	meanY /= float64(len(t.samples))
+
+	var cov, variance float64
+	for _, s := range t.samples {
+		cov += (s.replicas - meanX) * (s.rate - meanY)
+		variance += (s.replicas - meanX) * (s.replicas - meanX)
+	}
+
+	if variance == 0 {
+		return 0, false
+	}
+
+	slope := cov / variance
+	if slope >= 0 {
+		return 0, false
+	}
+
+	return -slope, true
+}
+
+// Learned returns true once a throughput is known.
+func (t *Throughput) Learned() bool {
+	return t.PerPod > 0
+}
+
+// DesiredReplicas returns the replicas needed to drain numMessages within
+// drainTime at the learned throughput.
+func (t *Throughput) DesiredReplicas(numMessages int, drainTime time.Duration) int {
+	if !t.Learned() || drainTime <= 0 {
+		return 0
+	}
+
+	return int(math.Ceil(float64(numMessages) / (t.PerPod * drainTime.Seconds())))
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThroughputLearns(t *testing.T) {
+	// 20 messages/s arrive and each pod drains 5 messages/s
+	th := NewThroughput(10*time.Minute, 1, 0)
+	now := time.Now()
+	depth := 1000
+
+	replicas := []int{2, 2, 3, 3, 4, 4, 6, 6, 8}
+	for i, r := range replicas {
+		th.Observe(depth, r, now.Add(time.Duration(i)*10*time.Second))
+		depth += (20 - 5*r) * 10
+	}
+
+	assert.True(t, th.Learned())
+	assert.InDelta(t, 5, th.PerPod, 0.001)
+}
+
+func TestThroughputNeedsDifferentReplicas(t *testing.T) {
+	th := NewThroughput(10*time.Minute, 1, 0)
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		assert.False(t, th.Observe(1000-i*50, 4, now.Add(time.Duration(i)*10*time.Second)))
+	}
+
+	assert.False(t, th.Learned(), "A single replica count cannot separate inflow from throughput")
+}
+
+func TestThroughputSkipsEmptyQueue(t *testing.T) {
+	th := NewThroughput(10*time.Minute, 1, 0)
+	now := time.Now()
+
+	for i, r := range []int{1, 2, 3, 4, 5} {
+		th.Observe(0, r, now.Add(time.Duration(i)*10*time.Second))
+	}
+
+	assert.False(t, th.Learned())
+}
+
+func TestThroughputSmoothing(t *testing.T) {
+	th := NewThroughput(10*time.Minute, 0.5, 10)
+	now := time.Now()
+	depth := 1000
+
+	for i, r := range []int{2, 4, 2, 4} {
+		th.Observe(depth, r, now.Add(time.Duration(i)*10*time.Second))
+		depth += (20 - 5*r) * 10
+	}
+
+	// Each estimate of 5 halves the distance from the saved throughput
+	assert.True(t, th.PerPod > 5 && th.PerPod < 10)
+}
+
+func TestThroughputDesiredReplicas(t *testing.T) {
+	th := NewThroughput(time.Minute, 0.2, 0)
+	assert.Equal(t, 0, th.DesiredReplicas(1000, time.Minute), "Nothing is known before learning")
+
+	th.PerPod = 2.5
+	assert.Equal(t, 7, th.DesiredReplicas(1000, time.Minute))
+	assert.Equal(t, 0, th.DesiredReplicas(0, time.Minute))
+}
@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/Wattpad/kube-sqs-autoscaler/sqs"
+)
+
+// ScaleToZero removes all replicas once the queue has been empty, including
+// in-flight and delayed messages, for IdleAfter. As soon as any message
+// appears it wakes the deployment back up to ActivationReplicas.
+type ScaleToZero struct {
+	IdleAfter          time.Duration
+	ActivationReplicas int
+
+	Log *log.Entry
+
+	idleSince time.Time
+}
+
+func NewScaleToZero(idleAfter time.Duration, activationReplicas int) *ScaleToZero {
+	return &ScaleToZero{
+		IdleAfter:          idleAfter,
+		ActivationReplicas: activationReplicas,
+	}
+}
+
+// Replicas returns the replica count the deployment should be set to, and
+// true if scale to zero takes precedence over the regular scaling policy.
+// That is the case while the deployment is asleep or should go to sleep, and
+// when it needs to be woken up.
+func (z *ScaleToZero) Replicas(queue sqs.QueueAttributes, current int, now time.Time) (int, bool) {
+	if !queue.Empty() {
+		z.idleSince = time.Time{}
+
+		if current == 0 {
+			logger(z.Log).Infof("Messages arrived in queue, activating with %d replicas", z.ActivationReplicas)
+			return z.ActivationReplicas, true
+		}
+
+		return 0, false
+	}
+
+	if z.idleSince.IsZero() {
+		z.idleSince = now
+	}
+
+	if current == 0 {
+		return 0, true
+	}
+
+	if now.Sub(z.idleSince) >= z.IdleAfter {
+		logger(z.Log).Infof("Queue has been empty since %s, scaling to zero", z.idleSince.Format(time.RFC3339))
+		return 0, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,49 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Wattpad/kube-sqs-autoscaler/sqs"
+)
+
+func TestScaleToZero(t *testing.T) {
+	z := NewScaleToZero(time.Minute, 2)
+	now := time.Now()
+	empty := sqs.QueueAttributes{}
+
+	_, ok := z.Replicas(empty, 3, now)
+	assert.False(t, ok)
+
+	_, ok = z.Replicas(empty, 3, now.Add(30*time.Second))
+	assert.False(t, ok)
+
+	replicas, ok := z.Replicas(empty, 3, now.Add(time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 0, replicas)
+
+	// Stays asleep while the queue is empty
+	replicas, ok = z.Replicas(empty, 0, now.Add(2*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 0, replicas)
+
+	replicas, ok = z.Replicas(sqs.QueueAttributes{Visible: 1}, 0, now.Add(3*time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 2, replicas)
+}
+
+func TestScaleToZeroInFlight(t *testing.T) {
+	z := NewScaleToZero(time.Minute, 1)
+	now := time.Now()
+
+	z.Replicas(sqs.QueueAttributes{}, 3, now)
+
+	// In-flight messages reset the idle period
+	_, ok := z.Replicas(sqs.QueueAttributes{InFlight: 5}, 3, now.Add(50*time.Second))
+	assert.False(t, ok)
+
+	_, ok = z.Replicas(sqs.QueueAttributes{}, 3, now.Add(100*time.Second))
+	assert.False(t, ok)
+}
@@ -0,0 +1,68 @@
+package scale
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// OwnerAnnotation names the autoscaler instance that scales a resource and
+// when it last claimed it, in the form id/time.
+const OwnerAnnotation = "kube-sqs-autoscaler/owner"
+
+// ExternalChange compares the current replicas with the replicas the
+// autoscaler last set or saw, and returns those and true if someone else
+// changed them in between. The current replicas become the new baseline, so
+// each change is reported once.
+func (p *PodAutoScaler) ExternalChange(current int) (int, bool) {
+	last, known := p.lastReplicas, p.knowsReplicas
+	p.lastReplicas, p.knowsReplicas = current, true
+
+	return last, known && last != current
+}
+
+// HorizontalPodAutoscaler returns the name of a HorizontalPodAutoscaler that
+// scales the same resource, or an empty string if there is none.
+func (p *PodAutoScaler) HorizontalPodAutoscaler() (string, error) {
+	list, err := p.Client.HorizontalPodAutoscalers(p.Namespace).List(api.ListOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to list horizontal pod autoscalers from kube server")
+	}
+
+	for _, hpa := range list.Items {
+		ref := hpa.Spec.ScaleTargetRef
+		if ref.Name == p.Deployment && strings.EqualFold(ref.Kind, p.kind().Name()) {
+			return hpa.Name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// Owner returns the autoscaler instance that last claimed the resource and
+// when, or an empty id if no instance has.
+func (p *PodAutoScaler) Owner() (string, time.Time, error) {
+	value, err := p.Annotation(OwnerAnnotation)
+	if err != nil || value == "" {
+		return "", time.Time{}, err
+	}
+
+	i := strings.LastIndex(value, "/")
+	if i < 0 {
+		return value, time.Time{}, nil
+	}
+
+	// An owner with an unreadable time is treated as long gone
+	claimed, _ := time.Parse(time.RFC3339, value[i+1:])
+	return value[:i], claimed, nil
+}
+
+// Claim marks the resource as scaled by the autoscaler instance id at now.
+// Claims are renewed periodically, so that an instance that went away can
+// be told apart from one that is still scaling.
+func (p *PodAutoScaler) Claim(id string, now time.Time) error {
+	return p.Annotate(OwnerAnnotation, id+"/"+now.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,88 @@
+package scale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/autoscaling"
+)
+
+func TestExternalChange(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+
+	_, changed := p.ExternalChange(3)
+	assert.False(t, changed, "The first replicas seen are the baseline")
+
+	assert.Nil(t, p.ScaleUp())
+	_, changed = p.ExternalChange(4)
+	assert.False(t, changed, "Changes by the autoscaler are not external")
+
+	last, changed := p.ExternalChange(2)
+	assert.True(t, changed)
+	assert.Equal(t, 4, last)
+
+	_, changed = p.ExternalChange(2)
+	assert.False(t, changed, "Each change is reported once")
+}
+
+func TestHorizontalPodAutoscaler(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+	client := p.Client.(*MockKubeClient)
+
+	hpa := func(name string, kind string, target string) autoscaling.HorizontalPodAutoscaler {
+		return autoscaling.HorizontalPodAutoscaler{
+			ObjectMeta: api.ObjectMeta{Name: name},
+			Spec: autoscaling.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscaling.CrossVersionObjectReference{Kind: kind, Name: target},
+			},
+		}
+	}
+	client.HPAs = []autoscaling.HorizontalPodAutoscaler{
+		hpa("other", "Deployment", "other"),
+		hpa("statefulset", "StatefulSet", "test"),
+	}
+
+	name, err := p.HorizontalPodAutoscaler()
+	assert.Nil(t, err)
+	assert.Equal(t, "", name)
+
+	client.HPAs = append(client.HPAs, hpa("test", "Deployment", "test"))
+	name, err = p.HorizontalPodAutoscaler()
+	assert.Nil(t, err)
+	assert.Equal(t, "test", name)
+
+	p.Kind = KindStatefulSet
+	name, err = p.HorizontalPodAutoscaler()
+	assert.Nil(t, err)
+	assert.Equal(t, "statefulset", name)
+}
+
+func TestOwner(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+
+	id, _, err := p.Owner()
+	assert.Nil(t, err)
+	assert.Equal(t, "", id, "Nobody claimed the deployment yet")
+
+	now := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Nil(t, p.Claim("autoscaler-1", now))
+	id, claimed, err := p.Owner()
+	assert.Nil(t, err)
+	assert.Equal(t, "autoscaler-1", id)
+	assert.True(t, now.Equal(claimed))
+
+	assert.Nil(t, p.Annotate(OwnerAnnotation, "autoscaler-2"))
+	id, claimed, err = p.Owner()
+	assert.Nil(t, err)
+	assert.Equal(t, "autoscaler-2", id)
+	assert.True(t, claimed.IsZero())
+}
+
+func TestKindName(t *testing.T) {
+	assert.Equal(t, "Deployment", Kind("").Name())
+	assert.Equal(t, "StatefulSet", KindStatefulSet.Name())
+	assert.Equal(t, "ReplicaSet", Kind("ReplicaSet.v1.apps").Name())
+}
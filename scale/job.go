@@ -0,0 +1,228 @@
+package scale
+
+import (
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	log "github.com/Sirupsen/logrus"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// JobLabel is set on every Job the autoscaler creates, with the name of the
+// JobScaler as its value, so that only those Jobs are counted and cleaned up.
+const JobLabel = "kube-sqs-autoscaler/job"
+
+// JobClient creates and cleans up Jobs.
+type JobClient interface {
+	Jobs(namespace string) kclient.JobInterface
+}
+
+func (c *kubeClient) Jobs(namespace string) kclient.JobInterface {
+	return c.BatchClient.Jobs(namespace)
+}
+
+// NewJobClient returns a client for the Jobs of the cluster that shares the
+// connection of a client returned by NewKubeClient.
+func NewJobClient(client KubeClient) JobClient {
+	return client.(*kubeClient)
+}
+
+// LoadJobTemplate reads a Job manifest in YAML or JSON that Jobs are created
+// from.
+func LoadJobTemplate(path string) (*batch.Job, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read job template")
+	}
+
+	return ParseJobTemplate(data)
+}
+
+// ParseJobTemplate decodes a Job manifest in YAML or JSON.
+func ParseJobTemplate(data []byte) (*batch.Job, error) {
+	obj, err := runtime.Decode(api.Codecs.UniversalDecoder(), data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decode job template")
+	}
+
+	job, ok := obj.(*batch.Job)
+	if !ok {
+		return nil, errors.Errorf("Job template is a %T, expected a Job", obj)
+	}
+
+	return job, nil
+}
+
+// JobScaler works off a backlog by creating Jobs from a template instead of
+// scaling the replicas of a resource, so that no pod is ever stopped in the
+// middle of a message. Each Job is expected to process up to MessagesPerJob
+// messages and exit.
+type JobScaler struct {
+	Client    JobClient
+	Namespace string
+	// Name is the prefix of the names of the Jobs created, and the value of
+	// their JobLabel.
+	Name     string
+	Template *batch.Job
+
+	// Max is the most Jobs that run at once.
+	Max            int
+	MessagesPerJob int
+
+	// The number of finished Jobs of each outcome kept for inspection. Older
+	// ones are deleted along with their pods.
+	SuccessfulJobsHistory int
+	FailedJobsHistory     int
+
+	// Log receives the scaler's log lines. Defaults to the standard logger.
+	Log *log.Entry
+}
+
+func (j *JobScaler) logger() *log.Entry {
+	if j.Log == nil {
+		return log.NewEntry(log.StandardLogger())
+	}
+	return j.Log
+}
+
+// finished returns the condition a Job finished with, or nil if it is still
+// running.
+func finished(job *batch.Job) *batch.JobCondition {
+	for i := range job.Status.Conditions {
+		c := &job.Status.Conditions[i]
+		if (c.Type == batch.JobComplete || c.Type == batch.JobFailed) && c.Status == api.ConditionTrue {
+			return c
+		}
+	}
+	return nil
+}
+
+// DesiredJobs returns the number of Jobs that should be running for the given
+// number of messages, including those still being processed.
+func (j *JobScaler) DesiredJobs(messages int) int {
+	perJob := j.MessagesPerJob
+	if perJob <= 0 {
+		perJob = 1
+	}
+
+	desired := (messages + perJob - 1) / perJob
+	if desired > j.Max {
+		return j.Max
+	}
+	return desired
+}
+
+// Scale creates the Jobs needed for the given number of messages on top of
+// the ones still running, and deletes finished Jobs beyond the history
+// limits. It returns the number of running Jobs afterwards.
+func (j *JobScaler) Scale(messages int) (int, error) {
+	client := j.Client.Jobs(j.Namespace)
+
+	list, err := client.List(api.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{JobLabel: j.Name}),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to list jobs from kube server")
+	}
+
+	running := 0
+	var succeeded, failed []batch.Job
+	for _, job := range list.Items {
+		c := finished(&job)
+		switch {
+		case c == nil:
+			running++
+		case c.Type == batch.JobComplete:
+			succeeded = append(succeeded, job)
+		default:
+			failed = append(failed, job)
+		}
+	}
+
+	j.cleanUp(client, succeeded, j.SuccessfulJobsHistory)
+	j.cleanUp(client, failed, j.FailedJobsHistory)
+
+	create := j.DesiredJobs(messages) - running
+	for i := 0; i < create; i++ {
+		job, err := j.newJob()
+		if err != nil {
+			return running, err
+		}
+
+		if _, err := client.Create(job); err != nil {
+			return running, errors.Wrap(err, "Failed to create job")
+		}
+		running++
+	}
+
+	if create > 0 {
+		j.logger().Infof("Created %d jobs. Running jobs: %d", create, running)
+	}
+
+	return running, nil
+}
+
+// newJob returns a copy of the template to create.
+func (j *JobScaler) newJob() (*batch.Job, error) {
+	obj, err := api.Scheme.DeepCopy(j.Template)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to copy job template")
+	}
+	template := obj.(*batch.Job)
+
+	job := &batch.Job{
+		ObjectMeta: api.ObjectMeta{
+			GenerateName: j.Name + "-",
+			Namespace:    j.Namespace,
+			Labels:       map[string]string{},
+			Annotations:  template.Annotations,
+		},
+		Spec: template.Spec,
+	}
+	for k, v := range template.Labels {
+		job.Labels[k] = v
+	}
+	job.Labels[JobLabel] = j.Name
+
+	// A selector copied from an existing Job matches only that Job's pods,
+	// let the api server generate one
+	if job.Spec.ManualSelector == nil || !*job.Spec.ManualSelector {
+		job.Spec.Selector = nil
+	}
+
+	return job, nil
+}
+
+// cleanUp deletes the oldest of the finished Jobs beyond the most to keep.
+// Failures are only logged, they are retried on the next poll.
+func (j *JobScaler) cleanUp(client kclient.JobInterface, jobs []batch.Job, keep int) {
+	if len(jobs) <= keep {
+		return
+	}
+
+	sort.Sort(byFinishTime(jobs))
+
+	// Delete the pods with the job
+	orphan := false
+	for _, job := range jobs[:len(jobs)-keep] {
+		if err := client.Delete(job.Name, &api.DeleteOptions{OrphanDependents: &orphan}); err != nil {
+			j.logger().Errorf("Failed deleting finished job %s: %v", job.Name, err)
+		}
+	}
+}
+
+// byFinishTime sorts finished Jobs from the oldest.
+type byFinishTime []batch.Job
+
+func (s byFinishTime) Len() int      { return len(s) }
+func (s byFinishTime) Swap(i, k int) { s[i], s[k] = s[k], s[i] }
+func (s byFinishTime) Less(i, k int) bool {
+	return finished(&s[i]).LastTransitionTime.Before(finished(&s[k]).LastTransitionTime)
+}
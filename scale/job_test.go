@@ -0,0 +1,199 @@
+package scale
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+const jobTemplate = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: worker
+  labels:
+    app: worker
+spec:
+  selector:
+    matchLabels:
+      controller-uid: 0b0a9c6e
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: worker
+        image: example.com/worker
+`
+
+type MockJobClient struct {
+	// stores the Jobs as if the api server did
+	Items   []batch.Job
+	Deleted []string
+	created int
+}
+
+type MockJobs struct {
+	client *MockJobClient
+}
+
+func (m *MockJobClient) Jobs(namespace string) kclient.JobInterface {
+	return &MockJobs{client: m}
+}
+
+func (m *MockJobs) List(opts api.ListOptions) (*batch.JobList, error) {
+	list := &batch.JobList{}
+	for _, job := range m.client.Items {
+		if opts.LabelSelector.Matches(labels.Set(job.Labels)) {
+			list.Items = append(list.Items, job)
+		}
+	}
+	return list, nil
+}
+
+func (m *MockJobs) Get(name string) (*batch.Job, error) {
+	return nil, nil
+}
+
+func (m *MockJobs) Create(job *batch.Job) (*batch.Job, error) {
+	m.client.created++
+	job.Name = fmt.Sprintf("%s%d", job.GenerateName, m.client.created)
+	m.client.Items = append(m.client.Items, *job)
+	return job, nil
+}
+
+func (m *MockJobs) Update(job *batch.Job) (*batch.Job, error) {
+	return nil, nil
+}
+
+func (m *MockJobs) Delete(name string, options *api.DeleteOptions) error {
+	for i, job := range m.client.Items {
+		if job.Name == name {
+			m.client.Items = append(m.client.Items[:i], m.client.Items[i+1:]...)
+			m.client.Deleted = append(m.client.Deleted, name)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *MockJobs) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, nil
+}
+
+func (m *MockJobs) UpdateStatus(job *batch.Job) (*batch.Job, error) {
+	return nil, nil
+}
+
+func NewMockJobScaler(max int) (*JobScaler, *MockJobClient) {
+	template, err := ParseJobTemplate([]byte(jobTemplate))
+	if err != nil {
+		panic(err)
+	}
+
+	client := &MockJobClient{}
+	return &JobScaler{
+		Client:                client,
+		Namespace:             "test",
+		Name:                  "worker",
+		Template:              template,
+		Max:                   max,
+		MessagesPerJob:        1,
+		SuccessfulJobsHistory: 1,
+		FailedJobsHistory:     1,
+	}, client
+}
+
+// finish marks the named job as finished with the condition at the given time.
+func finish(client *MockJobClient, name string, condition batch.JobConditionType, at time.Time) {
+	for i := range client.Items {
+		if client.Items[i].Name == name {
+			client.Items[i].Status.Conditions = []batch.JobCondition{{
+				Type:               condition,
+				Status:             api.ConditionTrue,
+				LastTransitionTime: unversioned.NewTime(at),
+			}}
+		}
+	}
+}
+
+func TestParseJobTemplate(t *testing.T) {
+	job, err := ParseJobTemplate([]byte(jobTemplate))
+	assert.Nil(t, err)
+	assert.Equal(t, "worker", job.Name)
+	assert.Equal(t, "example.com/worker", job.Spec.Template.Spec.Containers[0].Image)
+
+	_, err = ParseJobTemplate([]byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "worker"}}`))
+	assert.NotNil(t, err, "Only jobs are templates")
+}
+
+func TestDesiredJobs(t *testing.T) {
+	j, _ := NewMockJobScaler(10)
+	assert.Equal(t, 0, j.DesiredJobs(0))
+	assert.Equal(t, 3, j.DesiredJobs(3))
+	assert.Equal(t, 10, j.DesiredJobs(50))
+
+	j.MessagesPerJob = 4
+	assert.Equal(t, 1, j.DesiredJobs(3))
+	assert.Equal(t, 2, j.DesiredJobs(5))
+}
+
+func TestJobScalerScale(t *testing.T) {
+	j, client := NewMockJobScaler(5)
+
+	running, err := j.Scale(3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, running)
+	assert.Len(t, client.Items, 3)
+
+	job := client.Items[0]
+	assert.Equal(t, "worker-1", job.Name)
+	assert.Equal(t, "test", job.Namespace)
+	assert.Equal(t, map[string]string{"app": "worker", JobLabel: "worker"}, job.Labels)
+	assert.Nil(t, job.Spec.Selector, "The selector of the template should be generated again")
+
+	// Running jobs still hold their messages
+	running, err = j.Scale(3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, running)
+	assert.Len(t, client.Items, 3)
+
+	// Never more than max jobs
+	running, err = j.Scale(100)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, running)
+	assert.Len(t, client.Items, 5)
+
+	// Other jobs are not counted
+	client.Items = append(client.Items, batch.Job{ObjectMeta: api.ObjectMeta{Name: "other"}})
+	running, err = j.Scale(100)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, running)
+}
+
+func TestJobScalerCleanUp(t *testing.T) {
+	j, client := NewMockJobScaler(5)
+
+	_, err := j.Scale(5)
+	assert.Nil(t, err)
+
+	now := time.Now()
+	finish(client, "worker-1", batch.JobComplete, now.Add(-time.Minute))
+	finish(client, "worker-2", batch.JobComplete, now)
+	finish(client, "worker-3", batch.JobFailed, now.Add(-time.Hour))
+	finish(client, "worker-4", batch.JobFailed, now.Add(-2*time.Hour))
+
+	running, err := j.Scale(1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, running, "Finished jobs are not running")
+	assert.Equal(t, []string{"worker-1", "worker-4"}, client.Deleted, "The oldest finished jobs beyond the history should be deleted")
+	assert.Len(t, client.Items, 3)
+}
@@ -0,0 +1,220 @@
+package scale
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RateLimit bounds how many replicas may be added or removed within Period.
+// When Percent is set, Value is a percentage of the replicas at the start of
+// the period.
+type RateLimit struct {
+	Value   int
+	Percent bool
+	Period  time.Duration
+}
+
+func (l RateLimit) String() string {
+	if l.Percent {
+		return fmt.Sprintf("%d%%/%s", l.Value, l.Period)
+	}
+	return fmt.Sprintf("%d/%s", l.Value, l.Period)
+}
+
+// RateLimits is a list of rate limits for one scaling direction.
+type RateLimits []RateLimit
+
+func (r *RateLimits) String() string {
+	if r == nil {
+		return ""
+	}
+
+	var fields []string
+	for _, l := range *r {
+		fields = append(fields, l.String())
+	}
+	return strings.Join(fields, ",")
+}
+
+// Set implements flag.Value.
+func (r *RateLimits) Set(s string) error {
+	limits, err := ParseRateLimits(s)
+	if err != nil {
+		return err
+	}
+
+	*r = limits
+	return nil
+}
+
+// UnmarshalJSON accepts limits in the same form as the flag.
+func (r *RateLimits) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	return r.Set(s)
+}
+
+// ParseRateLimits parses a comma separated list of limits in the form
+// value/period, e.g. "10/1m,100%/1m".
+func ParseRateLimits(s string) (RateLimits, error) {
+	var limits RateLimits
+
+	if strings.TrimSpace(s) == "" {
+		return limits, nil
+	}
+
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(field), "/")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("Invalid rate limit %q, expected value/period", field)
+		}
+
+		percent := strings.HasSuffix(parts[0], "%")
+		value, err := strconv.Atoi(strings.TrimSuffix(parts[0], "%"))
+		if err != nil || value <= 0 {
+			return nil, errors.Errorf("Invalid value in rate limit %q", field)
+		}
+
+		period, err := time.ParseDuration(parts[1])
+		if err != nil || period <= 0 {
+			return nil, errors.Errorf("Invalid period in rate limit %q", field)
+		}
+
+		limits = append(limits, RateLimit{Value: value, Percent: percent, Period: period})
+	}
+
+	return limits, nil
+}
+
+// SelectPolicy chooses between several rate limits for the same direction.
+type SelectPolicy string
+
+const (
+	// SelectMax applies the limit allowing the largest change.
+	SelectMax SelectPolicy = "max"
+	// SelectMin applies the limit allowing the smallest change.
+	SelectMin SelectPolicy = "min"
+)
+
+func (s *SelectPolicy) String() string {
+	return string(*s)
+}
+
+// Set implements flag.Value.
+func (s *SelectPolicy) Set(v string) error {
+	switch SelectPolicy(v) {
+	case SelectMax, SelectMin:
+		*s = SelectPolicy(v)
+		return nil
+	}
+
+	return errors.Errorf("Invalid select policy %q, expected max or min", v)
+}
+
+// UnmarshalJSON rejects unknown select policies.
+func (s *SelectPolicy) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	return s.Set(v)
+}
+
+type replicaChange struct {
+	time  time.Time
+	delta int
+}
+
+// changedWithin returns the replicas added and removed since the given time.
+func (p *PodAutoScaler) changedWithin(since time.Time) (added int, removed int) {
+	for _, c := range p.changes {
+		if c.time.Before(since) {
+			continue
+		}
+		if c.delta > 0 {
+			added += c.delta
+		} else {
+			removed -= c.delta
+		}
+	}
+	return added, removed
+}
+
+// recordChange remembers a replica change so later changes can be rate
+// limited against it.
+func (p *PodAutoScaler) recordChange(delta int, now time.Time) {
+	p.changes = append(p.changes, replicaChange{time: now, delta: delta})
+
+	var longest time.Duration
+	for _, limits := range []RateLimits{p.ScaleUpLimits, p.ScaleDownLimits} {
+		for _, l := range limits {
+			if l.Period > longest {
+				longest = l.Period
+			}
+		}
+	}
+
+	for len(p.changes) > 0 && now.Sub(p.changes[0].time) > longest {
+		p.changes = p.changes[1:]
+	}
+}
+
+// rateLimit returns desired clamped by the rate limits for the direction of
+// the change from current.
+func (p *PodAutoScaler) rateLimit(current int, desired int, now time.Time) int {
+	if desired > current && len(p.ScaleUpLimits) > 0 {
+		var limit int
+		for i, l := range p.ScaleUpLimits {
+			added, _ := p.changedWithin(now.Add(-l.Period))
+			start := current - added
+
+			allowed := start + l.Value
+			if l.Percent {
+				allowed = start + int(math.Max(1, math.Ceil(float64(start)*float64(l.Value)/100)))
+			}
+
+			if i == 0 || (p.ScaleUpSelect == SelectMin && allowed < limit) || (p.ScaleUpSelect != SelectMin && allowed > limit) {
+				limit = allowed
+			}
+		}
+
+		if desired > limit {
+			p.logger().Infof("Scale up to %d limited to %d by rate limits %s", desired, limit, &p.ScaleUpLimits)
+			desired = int(math.Max(float64(current), float64(limit)))
+		}
+	}
+
+	if desired < current && len(p.ScaleDownLimits) > 0 {
+		var limit int
+		for i, l := range p.ScaleDownLimits {
+			_, removed := p.changedWithin(now.Add(-l.Period))
+			start := current + removed
+
+			allowed := start - l.Value
+			if l.Percent {
+				allowed = start - int(math.Max(1, math.Floor(float64(start)*float64(l.Value)/100)))
+			}
+
+			if i == 0 || (p.ScaleDownSelect == SelectMin && allowed > limit) || (p.ScaleDownSelect != SelectMin && allowed < limit) {
+				limit = allowed
+			}
+		}
+
+		if desired < limit {
+			p.logger().Infof("Scale down to %d limited to %d by rate limits %s", desired, limit, &p.ScaleDownLimits)
+			desired = int(math.Min(float64(current), float64(limit)))
+		}
+	}
+
+	return desired
+}
@@ -0,0 +1,91 @@
+package scale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits("10/1m, 100%/1m")
+	assert.Nil(t, err)
+	assert.Equal(t, RateLimits{
+		{Value: 10, Period: time.Minute},
+		{Value: 100, Percent: true, Period: time.Minute},
+	}, limits)
+	assert.Equal(t, "10/1m0s,100%/1m0s", limits.String())
+
+	_, err = ParseRateLimits("10")
+	assert.NotNil(t, err)
+
+	_, err = ParseRateLimits("0/1m")
+	assert.NotNil(t, err)
+
+	_, err = ParseRateLimits("10/soon")
+	assert.NotNil(t, err)
+}
+
+func TestRateLimitScaleUp(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 100, 1)
+	p.ScaleUpLimits = RateLimits{
+		{Value: 4, Period: time.Minute},
+		{Value: 100, Percent: true, Period: time.Minute},
+	}
+	now := time.Now()
+
+	// The percentage limit allows the largest change
+	assert.Equal(t, 20, p.rateLimit(10, 50, now))
+
+	p.ScaleUpSelect = SelectMin
+	assert.Equal(t, 14, p.rateLimit(10, 50, now))
+
+	// Changes earlier in the period count against the limit
+	p.recordChange(3, now.Add(-30*time.Second))
+	assert.Equal(t, 11, p.rateLimit(10, 50, now))
+	assert.Equal(t, 14, p.rateLimit(10, 50, now.Add(time.Minute)))
+
+	// Scaling down is not limited by scale up limits
+	assert.Equal(t, 1, p.rateLimit(10, 1, now))
+}
+
+func TestRateLimitScaleDown(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 100, 1)
+	p.ScaleDownLimits = RateLimits{{Value: 10, Percent: true, Period: 5 * time.Minute}}
+	now := time.Now()
+
+	assert.Equal(t, 45, p.rateLimit(50, 10, now))
+
+	p.recordChange(-5, now)
+	assert.Equal(t, 45, p.rateLimit(45, 10, now.Add(time.Minute)))
+
+	// Percentages always allow at least one pod
+	assert.Equal(t, 2, p.rateLimit(3, 1, now.Add(10*time.Minute)))
+}
+
+func TestScaleToRateLimited(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 100, 1)
+	p.ScaleUpLimits = RateLimits{{Value: 2, Period: time.Minute}}
+
+	scaled, err := p.ScaleTo(50)
+	assert.Nil(t, err)
+	assert.True(t, scaled)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(5), deployment.Replicas)
+
+	scaled, err = p.ScaleTo(50)
+	assert.Nil(t, err)
+	assert.False(t, scaled, "A request the rate limits leave at the current replicas should not count as scaling")
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(5), deployment.Replicas)
+
+	err = p.ScaleUp()
+	assert.NotNil(t, err)
+
+	// Scaling to zero ignores rate limits
+	p.ScaleDownLimits = RateLimits{{Value: 1, Period: time.Minute}}
+	err = p.ScaleToZero()
+	assert.Nil(t, err)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(0), deployment.Replicas)
+}
@@ -1,27 +1,65 @@
 package scale
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/pkg/errors"
 
 	log "github.com/Sirupsen/logrus"
 
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/client/restclient"
 	kclient "k8s.io/kubernetes/pkg/client/unversioned"
 )
 
 type KubeClient interface {
-	Deployments(namespace string) kclient.DeploymentInterface
+	Scales(resource Resource, namespace string) ScaleInterface
+	HorizontalPodAutoscalers(namespace string) kclient.HorizontalPodAutoscalerInterface
 }
 
 type PodAutoScaler struct {
-	Client     KubeClient
-	Max        int
-	Min        int
+	Client KubeClient
+	Max    int
+	Min    int
+	// Deployment is the name of the resource to scale, a deployment unless
+	// Kind says otherwise. Its replicas are written through its scale
+	// subresource.
 	Deployment string
 	Namespace  string
+	Kind       Kind
+
+	// Rate limits on changes to the replicas, and how to choose between
+	// several limits for the same direction. A missing select policy
+	// behaves like SelectMax.
+	ScaleUpLimits   RateLimits
+	ScaleDownLimits RateLimits
+	ScaleUpSelect   SelectPolicy
+	ScaleDownSelect SelectPolicy
+
+	// Log receives the autoscaler's log lines. Defaults to the standard
+	// logger.
+	Log *log.Entry
+
+	changes []replicaChange
+
+	// The replicas last set or seen, to detect changes by others
+	lastReplicas  int
+	knowsReplicas bool
 }
 
-func NewPodAutoScaler(kubernetesDeploymentName string, kubernetesNamespace string, max int, min int) *PodAutoScaler {
+// kubeClient adds scale subresources to the client library.
+type kubeClient struct {
+	*kclient.Client
+}
+
+func (c *kubeClient) Scales(resource Resource, namespace string) ScaleInterface {
+	return &scales{client: c.RESTClient, resource: resource, namespace: namespace}
+}
+
+// NewKubeClient returns a client for the cluster the autoscaler runs in. It
+// can be shared by the autoscalers of several deployments.
+func NewKubeClient() KubeClient {
 	config, err := restclient.InClusterConfig()
 	if err != nil {
 		panic("Failed to configure incluster config")
@@ -32,8 +70,12 @@ func NewPodAutoScaler(kubernetesDeploymentName string, kubernetesNamespace strin
 		panic("Failed to configure client")
 	}
 
+	return &kubeClient{k8sClient}
+}
+
+func NewPodAutoScaler(kubernetesDeploymentName string, kubernetesNamespace string, max int, min int) *PodAutoScaler {
 	return &PodAutoScaler{
-		Client:     k8sClient,
+		Client:     NewKubeClient(),
 		Min:        min,
 		Max:        max,
 		Deployment: kubernetesDeploymentName,
@@ -41,48 +83,244 @@ func NewPodAutoScaler(kubernetesDeploymentName string, kubernetesNamespace strin
 	}
 }
 
-func (p *PodAutoScaler) ScaleUp() error {
-	deployment, err := p.Client.Deployments(p.Namespace).Get(p.Deployment)
-	if err != nil {
-		return errors.Wrap(err, "Failed to get deployment from kube server, no scale up occured")
+func (p *PodAutoScaler) kind() Kind {
+	if p.Kind == "" {
+		return KindDeployment
 	}
+	return p.Kind
+}
 
-	currentReplicas := deployment.Spec.Replicas
+// target returns the scaled resource. Every kind is scaled through its scale
+// subresource.
+func (p *PodAutoScaler) target() ScaleTarget {
+	// Kinds are validated when they are set
+	resource, _ := p.kind().Resource()
+	return &subresourceTarget{client: p.Client.Scales(resource, p.Namespace), name: p.Deployment}
+}
 
-	if currentReplicas >= int32(p.Max) {
-		return errors.New("Max pods reached")
+func (p *PodAutoScaler) logger() *log.Entry {
+	if p.Log == nil {
+		return log.NewEntry(log.StandardLogger())
 	}
+	return p.Log
+}
+
+// Adjustment is a change to the number of replicas relative to the current
+// count. When Percent is set, Value is a percentage of the current replicas.
+type Adjustment struct {
+	Value   int
+	Percent bool
+}
+
+// Apply returns the replica count after applying the adjustment to current.
+// Percentage adjustments are truncated but always change the count by at
+// least one pod.
+func (a Adjustment) Apply(current int) int {
+	if !a.Percent {
+		return current + a.Value
+	}
+
+	delta := current * a.Value / 100
+	if delta == 0 && a.Value > 0 {
+		delta = 1
+	}
+	if delta == 0 && a.Value < 0 {
+		delta = -1
+	}
+
+	return current + delta
+}
+
+func (a Adjustment) String() string {
+	if a.Percent {
+		return fmt.Sprintf("%+d%%", a.Value)
+	}
+	return fmt.Sprintf("%+d", a.Value)
+}
+
+func (p *PodAutoScaler) ScaleUp() error {
+	return p.ScaleBy(Adjustment{Value: 1})
+}
+
+func (p *PodAutoScaler) ScaleDown() error {
+	return p.ScaleBy(Adjustment{Value: -1})
+}
+
+// ScaleBy changes the replicas of the deployment by the given adjustment,
+// bounded by Min and Max.
+func (p *PodAutoScaler) ScaleBy(a Adjustment) error {
+	if a.Value == 0 {
+		return nil
+	}
+
+	direction := "up"
+	if a.Value < 0 {
+		direction = "down"
+	}
+
+	_, replicas, err := p.updateReplicas(func(current int, now time.Time) (int, error) {
+		if a.Value > 0 && current >= p.Max {
+			return current, errors.New("Max pods reached")
+		}
 
-	deployment.Spec.Replicas = currentReplicas + 1
+		if a.Value < 0 && current <= p.Min {
+			return current, errors.New("Min pods reached")
+		}
 
-	_, err = p.Client.Deployments(p.Namespace).Update(deployment)
+		replicas := p.rateLimit(current, p.Bound(a.Apply(current)), now)
+		if replicas == current {
+			return current, errors.Errorf("Scale %s rate limit reached", direction)
+		}
+
+		return replicas, nil
+	})
 	if err != nil {
-		return errors.Wrap(err, "Failed to scale up")
+		return err
 	}
 
-	log.Infof("Scale up successful. Replicas: %d", deployment.Spec.Replicas)
+	p.logger().Infof("Scale %s successful. Replicas: %d", direction, replicas)
 	return nil
 }
 
-func (p *PodAutoScaler) ScaleDown() error {
-	deployment, err := p.Client.Deployments(p.Namespace).Get(p.Deployment)
+func (p *PodAutoScaler) CurrentReplicas() (int, error) {
+	scale, err := p.target().GetScale()
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed to get %s from kube server", p.kind())
+	}
+
+	return scale.Replicas, nil
+}
+
+// ScaleTo sets the replicas of the deployment to the given count in a single
+// update, bounded by Min and Max and subject to the rate limits. It returns
+// false if the limits left the replicas unchanged.
+func (p *PodAutoScaler) ScaleTo(replicas int) (bool, error) {
+	return p.setReplicas(func(current int, now time.Time) int {
+		return p.rateLimit(current, p.Bound(replicas), now)
+	})
+}
+
+// Bound returns replicas limited to the range between Min and Max.
+func (p *PodAutoScaler) Bound(replicas int) int {
+	if replicas > p.Max {
+		return p.Max
+	}
+	if replicas < p.Min {
+		return p.Min
+	}
+
+	return replicas
+}
+
+// ScaleToZero removes all replicas of the deployment, ignoring Min and the
+// rate limits.
+func (p *PodAutoScaler) ScaleToZero() error {
+	_, err := p.setReplicas(func(int, time.Time) int { return 0 })
+	return err
+}
+
+// setReplicas updates the deployment to the replica count returned by desired
+// for its current replicas, and returns whether the replicas changed.
+func (p *PodAutoScaler) setReplicas(desired func(current int, now time.Time) int) (bool, error) {
+	current, replicas, err := p.updateReplicas(func(current int, now time.Time) (int, error) {
+		return desired(current, now), nil
+	})
+	if err != nil || replicas == current {
+		return false, err
+	}
+
+	p.logger().Infof("Scale successful. Replicas: %d", replicas)
+	return true, nil
+}
+
+// conflictRetries is how many times the replicas are read and written again
+// when another writer changed the resource in between.
+const conflictRetries = 5
+
+// updateReplicas writes the replica count returned by desired for the
+// current replicas through the scale subresource. When the resource was
+// changed since it was read, the update fails with a conflict and is retried
+// from the new current replicas. It returns the replicas before and after.
+func (p *PodAutoScaler) updateReplicas(desired func(current int, now time.Time) (int, error)) (int, int, error) {
+	target := p.target()
+
+	for attempt := 1; ; attempt++ {
+		scale, err := target.GetScale()
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "Failed to get %s from kube server", p.kind())
+		}
+
+		now := time.Now()
+		current := scale.Replicas
+
+		replicas, err := desired(current, now)
+		if err != nil || replicas == current {
+			return current, current, err
+		}
+
+		scale.Replicas = replicas
+
+		_, err = target.UpdateScale(scale)
+		if err == nil {
+			p.recordChange(replicas-current, now)
+			p.lastReplicas, p.knowsReplicas = replicas, true
+			return current, replicas, nil
+		}
+
+		if !apierrors.IsConflict(err) {
+			return current, current, errors.Wrapf(err, "Failed to scale %s", p.kind())
+		}
+
+		if attempt == conflictRetries {
+			return current, current, errors.Wrapf(err, "Failed to scale %s after %d conflicts", p.kind(), attempt)
+		}
+
+		p.logger().Warnf("Conflict scaling %s from %d to %d replicas, retrying (attempt %d of %d)", p.kind(), current, replicas, attempt, conflictRetries)
+	}
+}
+
+// Status returns the status of the scaled Deployment or StatefulSet. Other
+// kinds have no status the autoscaler understands, and return nil.
+func (p *PodAutoScaler) Status() (*Status, error) {
+	if k := p.kind(); k != KindDeployment && k != KindStatefulSet {
+		return nil, nil
+	}
+
+	status, err := p.target().Status()
 	if err != nil {
-		return errors.Wrap(err, "Failed to get deployment from kube server, no scale down occured")
+		return nil, errors.Wrapf(err, "Failed to get %s from kube server", p.kind())
 	}
 
-	currentReplicas := deployment.Spec.Replicas
+	return status, nil
+}
 
-	if currentReplicas <= int32(p.Min) {
-		return errors.New("Min pods reached")
+// RolloutInProgress returns whether the scaled Deployment is paused or being
+// rolled out, and what it is waiting for. Other kinds are not checked for
+// rollouts, since StatefulSets that are updated on delete may never finish.
+func (p *PodAutoScaler) RolloutInProgress(status *Status) (bool, string) {
+	if p.kind() != KindDeployment {
+		return false, ""
 	}
 
-	deployment.Spec.Replicas = currentReplicas - 1
+	return status.RolloutInProgress()
+}
 
-	deployment, err = p.Client.Deployments(p.Namespace).Update(deployment)
+// Annotation returns the value of an annotation on the scaled resource, or
+// an empty string if it is not set.
+func (p *PodAutoScaler) Annotation(key string) (string, error) {
+	annotations, err := p.target().Annotations()
 	if err != nil {
-		return errors.Wrap(err, "Failed to scale down")
+		return "", errors.Wrapf(err, "Failed to get %s from kube server", p.kind())
+	}
+
+	return annotations[key], nil
+}
+
+// Annotate sets an annotation on the scaled resource.
+func (p *PodAutoScaler) Annotate(key string, value string) error {
+	if err := p.target().Annotate(key, value); err != nil {
+		return errors.Wrapf(err, "Failed to set annotation %s on %s", key, p.kind())
 	}
 
-	log.Infof("Scale down successful. Replicas: %d", deployment.Spec.Replicas)
 	return nil
 }
@@ -1,11 +1,15 @@
 package scale
 
 import (
-	"github.com/stretchr/testify/assert"
 	"testing"
 
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
 	"k8s.io/kubernetes/pkg/api"
-	"k8s.io/kubernetes/pkg/apis/extensions"
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/autoscaling"
 	kclient "k8s.io/kubernetes/pkg/client/unversioned"
 	"k8s.io/kubernetes/pkg/watch"
 )
@@ -16,18 +20,18 @@ func TestScaleUp(t *testing.T) {
 	// Scale up replicas until we reach the max (5).
 	// Scale up again and assert that we get an error back when trying to scale up replicas pass the max
 	err := p.ScaleUp()
-	deployment, _ := p.Client.Deployments("test").Get("test")
+	deployment := p.Client.(*MockKubeClient).Deployment()
 	assert.Nil(t, err)
-	assert.Equal(t, int32(4), deployment.Spec.Replicas)
+	assert.Equal(t, int32(4), deployment.Replicas)
 	err = p.ScaleUp()
 	assert.Nil(t, err)
-	deployment, _ = p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(5), deployment.Spec.Replicas)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(5), deployment.Replicas)
 
 	err = p.ScaleUp()
 	assert.NotNil(t, err)
-	deployment, _ = p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(5), deployment.Spec.Replicas)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(5), deployment.Replicas)
 }
 
 func TestScaleDown(t *testing.T) {
@@ -35,73 +39,266 @@ func TestScaleDown(t *testing.T) {
 
 	err := p.ScaleDown()
 	assert.Nil(t, err)
-	deployment, _ := p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(2), deployment.Spec.Replicas)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(2), deployment.Replicas)
 	err = p.ScaleDown()
 	assert.Nil(t, err)
-	deployment, _ = p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(1), deployment.Spec.Replicas)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(1), deployment.Replicas)
 
 	err = p.ScaleDown()
 	assert.NotNil(t, err)
-	deployment, _ = p.Client.Deployments("test").Get("test")
-	assert.Equal(t, int32(1), deployment.Spec.Replicas)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(1), deployment.Replicas)
 }
 
-type MockDeployment struct {
-	client *MockKubeClient
+func TestScaleTo(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+
+	_, err := p.ScaleTo(5)
+	assert.Nil(t, err)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(5), deployment.Replicas)
+
+	// Requests outside of min and max are clamped
+	_, err = p.ScaleTo(40)
+	assert.Nil(t, err)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(5), deployment.Replicas)
+
+	_, err = p.ScaleTo(0)
+	assert.Nil(t, err)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(1), deployment.Replicas)
+
+	replicas, err := p.CurrentReplicas()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, replicas)
+}
+
+func TestScaleToZero(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+
+	err := p.ScaleToZero()
+	assert.Nil(t, err)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(0), deployment.Replicas)
+
+	_, err = p.ScaleTo(2)
+	assert.Nil(t, err)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(2), deployment.Replicas)
+}
+
+func TestScaleBy(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 20, 1)
+
+	err := p.ScaleBy(Adjustment{Value: 5})
+	assert.Nil(t, err)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(8), deployment.Replicas)
+
+	err = p.ScaleBy(Adjustment{Value: 50, Percent: true})
+	assert.Nil(t, err)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(12), deployment.Replicas)
+
+	// Adjustments past the max are clamped
+	err = p.ScaleBy(Adjustment{Value: 100, Percent: true})
+	assert.Nil(t, err)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(20), deployment.Replicas)
+
+	err = p.ScaleBy(Adjustment{Value: 1})
+	assert.NotNil(t, err)
+
+	err = p.ScaleBy(Adjustment{Value: -30})
+	assert.Nil(t, err)
+	deployment = p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(1), deployment.Replicas)
+
+	err = p.ScaleBy(Adjustment{Value: -1})
+	assert.NotNil(t, err)
+}
+
+func TestAnnotate(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+
+	value, err := p.Annotation("example.com/key")
+	assert.Nil(t, err)
+	assert.Equal(t, "", value)
+
+	assert.Nil(t, p.Annotate("example.com/key", "value"))
+
+	value, err = p.Annotation("example.com/key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestScaleConflict(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+	client := p.Client.(*MockKubeClient)
+
+	// Conflicts are retried from the replicas read again
+	client.Conflicts = conflictRetries - 1
+	assert.Nil(t, p.ScaleUp())
+	assert.Equal(t, int32(4), client.Deployment().Replicas)
+	assert.Equal(t, 0, client.Conflicts)
+
+	client.Conflicts = conflictRetries + 1
+	_, err := p.ScaleTo(5)
+	assert.NotNil(t, err, "Retries should be bounded")
+	assert.Equal(t, int32(4), client.Deployment().Replicas)
+	assert.Equal(t, 1, client.Conflicts)
+}
+
+func TestAdjustmentApply(t *testing.T) {
+	assert.Equal(t, 5, Adjustment{Value: 2}.Apply(3))
+	assert.Equal(t, 1, Adjustment{Value: -2}.Apply(3))
+	assert.Equal(t, 15, Adjustment{Value: 50, Percent: true}.Apply(10))
+	assert.Equal(t, 9, Adjustment{Value: -10, Percent: true}.Apply(10))
+
+	// Percentages always move by at least one pod
+	assert.Equal(t, 2, Adjustment{Value: 10, Percent: true}.Apply(1))
+	assert.Equal(t, 2, Adjustment{Value: -10, Percent: true}.Apply(3))
+}
+
+// MockObject is a Deployment, StatefulSet or other resource scaled through
+// its scale subresource, as the api server stores it.
+type MockObject struct {
+	Replicas    int32
+	Annotations map[string]string
 }
 
 type MockKubeClient struct {
-	// stores the state of Deployment as if the api server did
-	Deployment *extensions.Deployment
+	// Objects stores the scaled resources by resource, e.g. deployments, as
+	// if the api server did. Other resources are created on first use with
+	// 3 replicas.
+	Objects map[string]*MockObject
+	// Status is the status of every resource. When nil, they are settled
+	// with all their replicas available
+	Status *Status
+
+	// Conflicts is the number of scale updates that fail with a conflict
+	Conflicts int
+
+	// HPAs are the HorizontalPodAutoscalers in the namespace
+	HPAs []autoscaling.HorizontalPodAutoscaler
+}
+
+func (m *MockKubeClient) object(resource string) *MockObject {
+	o, ok := m.Objects[resource]
+	if !ok {
+		o = &MockObject{Replicas: 3}
+		m.Objects[resource] = o
+	}
+	return o
+}
+
+// Deployment returns the deployment scaled by default.
+func (m *MockKubeClient) Deployment() *MockObject {
+	return m.object("deployments")
+}
+
+// StatefulSet returns the statefulset scaled with KindStatefulSet.
+func (m *MockKubeClient) StatefulSet() *MockObject {
+	return m.object("statefulsets")
+}
+
+// MockScale reads and writes the replicas and annotations of the resource
+// the scale subresource belongs to.
+type MockScale struct {
+	client   *MockKubeClient
+	resource string
 }
 
-func (m *MockDeployment) Get(name string) (*extensions.Deployment, error) {
-	return m.client.Deployment, nil
+func (m *MockScale) Get(name string) (*Scale, error) {
+	return &Scale{Replicas: int(m.client.object(m.resource).Replicas)}, nil
 }
 
-func (m *MockDeployment) Update(deployment *extensions.Deployment) (*extensions.Deployment, error) {
-	m.client.Deployment.Spec.Replicas = deployment.Spec.Replicas
-	return m.client.Deployment, nil
+func (m *MockScale) Update(name string, s *Scale) (*Scale, error) {
+	if m.client.Conflicts > 0 {
+		m.client.Conflicts--
+		return nil, apierrors.NewConflict(unversioned.GroupResource{Resource: m.resource}, name, errors.New("Modified"))
+	}
+
+	m.client.object(m.resource).Replicas = int32(s.Replicas)
+	return m.Get(name)
+}
+
+func (m *MockScale) Status(name string) (*Status, error) {
+	if m.client.Status == nil {
+		replicas := m.client.object(m.resource).Replicas
+		return &Status{Replicas: replicas, UpdatedReplicas: replicas, AvailableReplicas: replicas}, nil
+	}
+	return m.client.Status, nil
+}
+
+func (m *MockScale) Annotations(name string) (map[string]string, error) {
+	return m.client.object(m.resource).Annotations, nil
+}
+
+func (m *MockScale) Annotate(name string, key string, value string) error {
+	o := m.client.object(m.resource)
+	if o.Annotations == nil {
+		o.Annotations = map[string]string{}
+	}
+	o.Annotations[key] = value
+	return nil
 }
 
-func (m *MockDeployment) List(opts api.ListOptions) (*extensions.DeploymentList, error) {
+func (m *MockKubeClient) Scales(resource Resource, namespace string) ScaleInterface {
+	return &MockScale{
+		client:   m,
+		resource: resource.Resource,
+	}
+}
+
+type MockHorizontalPodAutoscalers struct {
+	client *MockKubeClient
+}
+
+func (m *MockHorizontalPodAutoscalers) List(opts api.ListOptions) (*autoscaling.HorizontalPodAutoscalerList, error) {
+	return &autoscaling.HorizontalPodAutoscalerList{Items: m.client.HPAs}, nil
+}
+
+func (m *MockHorizontalPodAutoscalers) Get(name string) (*autoscaling.HorizontalPodAutoscaler, error) {
 	return nil, nil
 }
 
-func (m *MockDeployment) Delete(name string, options *api.DeleteOptions) error {
+func (m *MockHorizontalPodAutoscalers) Delete(name string, options *api.DeleteOptions) error {
 	return nil
 }
 
-func (m *MockDeployment) Create(*extensions.Deployment) (*extensions.Deployment, error) {
+func (m *MockHorizontalPodAutoscalers) Create(*autoscaling.HorizontalPodAutoscaler) (*autoscaling.HorizontalPodAutoscaler, error) {
 	return nil, nil
 }
 
-func (m *MockDeployment) UpdateStatus(*extensions.Deployment) (*extensions.Deployment, error) {
+func (m *MockHorizontalPodAutoscalers) Update(*autoscaling.HorizontalPodAutoscaler) (*autoscaling.HorizontalPodAutoscaler, error) {
 	return nil, nil
 }
 
-func (m *MockDeployment) Watch(opts api.ListOptions) (watch.Interface, error) {
+func (m *MockHorizontalPodAutoscalers) UpdateStatus(*autoscaling.HorizontalPodAutoscaler) (*autoscaling.HorizontalPodAutoscaler, error) {
 	return nil, nil
 }
 
-func (m *MockDeployment) Rollback(*extensions.DeploymentRollback) error {
-	return nil
+func (m *MockHorizontalPodAutoscalers) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, nil
 }
 
-func (m *MockKubeClient) Deployments(namespace string) kclient.DeploymentInterface {
-	return &MockDeployment{
+func (m *MockKubeClient) HorizontalPodAutoscalers(namespace string) kclient.HorizontalPodAutoscalerInterface {
+	return &MockHorizontalPodAutoscalers{
 		client: m,
 	}
 }
 
 func NewMockKubeClient() *MockKubeClient {
+	// the resources the run tests read while a target scales them are
+	// created up front
 	return &MockKubeClient{
-		Deployment: &extensions.Deployment{
-			Spec: extensions.DeploymentSpec{
-				Replicas: 3,
-			},
+		Objects: map[string]*MockObject{
+			"deployments":  {Replicas: 3},
+			"statefulsets": {Replicas: 3},
 		},
 	}
 }
@@ -0,0 +1,96 @@
+package scale
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Status is the progress of a Deployment or StatefulSet towards its spec, as
+// reported by its controller.
+type Status struct {
+	Paused             bool
+	Generation         int64
+	ObservedGeneration int64
+
+	// Replicas are the pods of the resource, UpdatedReplicas those of them
+	// with the current template, and AvailableReplicas those of them that
+	// have been ready for the minimum ready time.
+	Replicas          int32
+	UpdatedReplicas   int32
+	AvailableReplicas int32
+
+	// ProgressDeadlineExceeded is set once the deployment controller has
+	// given up waiting for a rollout to progress.
+	ProgressDeadlineExceeded bool
+}
+
+// UnmarshalJSON decodes the status of a Deployment or StatefulSet object.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var object struct {
+		Metadata struct {
+			Generation int64 `json:"generation"`
+		} `json:"metadata"`
+		Spec struct {
+			Paused bool `json:"paused"`
+		} `json:"spec"`
+		Status struct {
+			ObservedGeneration int64  `json:"observedGeneration"`
+			Replicas           int32  `json:"replicas"`
+			UpdatedReplicas    int32  `json:"updatedReplicas"`
+			ReadyReplicas      int32  `json:"readyReplicas"`
+			AvailableReplicas  *int32 `json:"availableReplicas"`
+			Conditions         []struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}
+
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+
+	*s = Status{
+		Paused:             object.Spec.Paused,
+		Generation:         object.Metadata.Generation,
+		ObservedGeneration: object.Status.ObservedGeneration,
+		Replicas:           object.Status.Replicas,
+		UpdatedReplicas:    object.Status.UpdatedReplicas,
+		// StatefulSets of older clusters only report ready replicas
+		AvailableReplicas: object.Status.ReadyReplicas,
+	}
+	if object.Status.AvailableReplicas != nil {
+		s.AvailableReplicas = *object.Status.AvailableReplicas
+	}
+	for _, c := range object.Status.Conditions {
+		if c.Type == "Progressing" && c.Reason == "ProgressDeadlineExceeded" {
+			s.ProgressDeadlineExceeded = true
+		}
+	}
+
+	return nil
+}
+
+// RolloutInProgress returns whether the Deployment is paused or being rolled
+// out, and what it is waiting for. A rollout is complete once the controller
+// has seen the latest spec and no pods of an older template are left. Unlike
+// kubectl rollout status, missing or unavailable pods do not count, so that
+// a scale up, or pods that cannot be scheduled, do not look like a rollout.
+// A rollout past its progress deadline is not waited for either, since it
+// may never finish.
+func (s *Status) RolloutInProgress() (bool, string) {
+	switch {
+	case s == nil:
+		return false, ""
+	case s.Paused:
+		return true, "deployment is paused"
+	case s.ObservedGeneration < s.Generation:
+		return true, fmt.Sprintf("waiting for the deployment controller to observe generation %d", s.Generation)
+	case s.ProgressDeadlineExceeded:
+		return false, ""
+	case s.Replicas > s.UpdatedReplicas:
+		return true, fmt.Sprintf("%d of %d replicas updated", s.UpdatedReplicas, s.Replicas)
+	}
+
+	return false, ""
+}
@@ -0,0 +1,112 @@
+package scale
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusJSON(t *testing.T) {
+	var s Status
+	err := json.Unmarshal([]byte(`{
+		"metadata": {"name": "worker", "generation": 4},
+		"spec": {"replicas": 3, "paused": true},
+		"status": {"observedGeneration": 3, "replicas": 4, "updatedReplicas": 1, "readyReplicas": 3, "availableReplicas": 2}
+	}`), &s)
+	assert.Nil(t, err)
+	assert.Equal(t, Status{
+		Paused:             true,
+		Generation:         4,
+		ObservedGeneration: 3,
+		Replicas:           4,
+		UpdatedReplicas:    1,
+		AvailableReplicas:  2,
+	}, s)
+
+	// StatefulSets of older clusters only report ready replicas
+	assert.Nil(t, json.Unmarshal([]byte(`{"status": {"replicas": 3, "readyReplicas": 3}}`), &s))
+	assert.Equal(t, int32(3), s.AvailableReplicas)
+
+	// The deployment controller gave up on the rollout
+	assert.Nil(t, json.Unmarshal([]byte(`{"status": {"conditions": [
+		{"type": "Available", "status": "True", "reason": "MinimumReplicasAvailable"},
+		{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"}
+	]}}`), &s))
+	assert.True(t, s.ProgressDeadlineExceeded)
+
+	// Zero available replicas are left out
+	assert.Nil(t, json.Unmarshal([]byte(`{"status": {"replicas": 3}}`), &s))
+	assert.Equal(t, int32(0), s.AvailableReplicas)
+}
+
+func TestStatusRolloutInProgress(t *testing.T) {
+	complete := func() *Status {
+		return &Status{Generation: 2, ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3}
+	}
+
+	var none *Status
+	ok, _ := none.RolloutInProgress()
+	assert.False(t, ok)
+
+	ok, _ = complete().RolloutInProgress()
+	assert.False(t, ok)
+
+	s := complete()
+	s.Paused = true
+	ok, reason := s.RolloutInProgress()
+	assert.True(t, ok)
+	assert.Equal(t, "deployment is paused", reason)
+
+	s = complete()
+	s.Generation = 3
+	ok, _ = s.RolloutInProgress()
+	assert.True(t, ok, "The controller has not seen the new spec yet")
+
+	s = complete()
+	s.Replicas = 4
+	s.UpdatedReplicas = 1
+	ok, reason = s.RolloutInProgress()
+	assert.True(t, ok)
+	assert.Equal(t, "1 of 4 replicas updated", reason)
+
+	s = complete()
+	s.Replicas = 4
+	s.UpdatedReplicas = 1
+	s.ProgressDeadlineExceeded = true
+	ok, _ = s.RolloutInProgress()
+	assert.False(t, ok, "A rollout past its progress deadline is not waited for")
+
+	s.Generation = 3
+	ok, _ = s.RolloutInProgress()
+	assert.True(t, ok, "A new spec starts a new rollout")
+
+	s = complete()
+	s.AvailableReplicas = 1
+	ok, _ = s.RolloutInProgress()
+	assert.False(t, ok, "Unavailable pods are not a rollout")
+}
+
+func TestPodAutoScalerStatus(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+	client := p.Client.(*MockKubeClient)
+	client.Status = &Status{Paused: true}
+
+	status, err := p.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, client.Status, status)
+	ok, _ := p.RolloutInProgress(status)
+	assert.True(t, ok)
+
+	p.Kind = KindStatefulSet
+	status, err = p.Status()
+	assert.Nil(t, err)
+	assert.Equal(t, client.Status, status)
+	ok, _ = p.RolloutInProgress(status)
+	assert.False(t, ok, "Only deployments are checked for rollouts")
+
+	p.Kind = "ReplicaSet.v1.apps"
+	status, err = p.Status()
+	assert.Nil(t, err)
+	assert.Nil(t, status, "Other kinds have no status")
+}
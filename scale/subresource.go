@@ -0,0 +1,144 @@
+package scale
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/restclient"
+)
+
+// ScaleInterface reads and writes the scale subresource of resources of one
+// kind in a namespace. Annotations and the status are read from the resource
+// itself, since the scale subresource has neither.
+type ScaleInterface interface {
+	Get(name string) (*Scale, error)
+	Update(name string, scale *Scale) (*Scale, error)
+	Annotations(name string) (map[string]string, error)
+	Annotate(name string, key string, value string) error
+	Status(name string) (*Status, error)
+}
+
+// scales implements ScaleInterface with the REST api. The autoscaling/v1
+// Scale objects are kept undecoded apart from the replicas, so they are
+// written back as read.
+type scales struct {
+	client    *restclient.RESTClient
+	resource  Resource
+	namespace string
+}
+
+func (c *scales) path(name string, subresource ...string) []string {
+	prefix := []string{"/apis", c.resource.Group, c.resource.Version}
+	if c.resource.Group == "" {
+		prefix = []string{"/api", c.resource.Version}
+	}
+
+	return append(append(prefix, "namespaces", c.namespace, c.resource.Resource, name), subresource...)
+}
+
+func decodeScale(data []byte) (*Scale, error) {
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode scale")
+	}
+
+	var replicas int
+	if spec, ok := object["spec"].(map[string]interface{}); ok {
+		if r, ok := spec["replicas"].(float64); ok {
+			replicas = int(r)
+		}
+	}
+
+	return &Scale{Replicas: replicas, object: object}, nil
+}
+
+func (c *scales) Get(name string) (*Scale, error) {
+	data, err := c.client.Get().AbsPath(c.path(name, "scale")...).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeScale(data)
+}
+
+func (c *scales) Update(name string, scale *Scale) (*Scale, error) {
+	object, _ := scale.object.(map[string]interface{})
+	if object == nil {
+		object = map[string]interface{}{
+			"apiVersion": "autoscaling/v1",
+			"kind":       "Scale",
+			"metadata":   map[string]interface{}{"name": name, "namespace": c.namespace},
+		}
+	}
+
+	spec, _ := object["spec"].(map[string]interface{})
+	if spec == nil {
+		spec = map[string]interface{}{}
+		object["spec"] = spec
+	}
+	spec["replicas"] = scale.Replicas
+
+	body, err := json.Marshal(object)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to encode scale")
+	}
+
+	data, err := c.client.Put().
+		AbsPath(c.path(name, "scale")...).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do().
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeScale(data)
+}
+
+func (c *scales) Annotations(name string) (map[string]string, error) {
+	data, err := c.client.Get().AbsPath(c.path(name)...).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	var object struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode annotations")
+	}
+
+	return object.Metadata.Annotations, nil
+}
+
+func (c *scales) Annotate(name string, key string, value string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{key: value},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to encode annotation")
+	}
+
+	return c.client.Patch(api.MergePatchType).AbsPath(c.path(name)...).Body(patch).Do().Error()
+}
+
+func (c *scales) Status(name string) (*Status, error) {
+	data, err := c.client.Get().AbsPath(c.path(name)...).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode status")
+	}
+
+	return status, nil
+}
@@ -0,0 +1,187 @@
+package scale
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apierrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/client/restclient"
+	kclient "k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+func TestKindResource(t *testing.T) {
+	cases := map[Kind]Resource{
+		KindDeployment:                  {Group: "apps", Version: "v1", Resource: "deployments"},
+		KindStatefulSet:                 {Group: "apps", Version: "v1", Resource: "statefulsets"},
+		"ReplicaSet.v1.apps":            {Group: "apps", Version: "v1", Resource: "replicasets"},
+		"ReplicationController.v1":      {Version: "v1", Resource: "replicationcontrollers"},
+		"Rollout.v1alpha1.argoproj.io":  {Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"},
+		"WorkerPolicy.v1.example.com":   {Group: "example.com", Version: "v1", Resource: "workerpolicies"},
+		"IngressClass.v1.example.com":   {Group: "example.com", Version: "v1", Resource: "ingressclasses"},
+		"Gateway.v1beta1.example.com":   {Group: "example.com", Version: "v1beta1", Resource: "gateways"},
+		"ReplicaArray.v2.db.example.io": {Group: "db.example.io", Version: "v2", Resource: "replicaarrays"},
+	}
+
+	for kind, resource := range cases {
+		r, err := kind.Resource()
+		assert.Nil(t, err, string(kind))
+		assert.Equal(t, resource, r, string(kind))
+	}
+
+	_, err := Kind("ReplicaSet").Resource()
+	assert.NotNil(t, err, "A version is required")
+	_, err = Kind(".v1.apps").Resource()
+	assert.NotNil(t, err, "A kind is required")
+}
+
+func TestKindSet(t *testing.T) {
+	var k Kind
+	assert.Nil(t, k.Set("deployment"))
+	assert.Equal(t, KindDeployment, k)
+	assert.Nil(t, k.Set("ReplicaSet.v1.apps"))
+	assert.Equal(t, Kind("ReplicaSet.v1.apps"), k)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`"replicaset"`), &k))
+	assert.Nil(t, json.Unmarshal([]byte(`"Rollout.v1alpha1.argoproj.io"`), &k))
+	assert.Equal(t, Kind("Rollout.v1alpha1.argoproj.io"), k)
+}
+
+func TestSubresourceScale(t *testing.T) {
+	p := NewMockPodAutoScaler("test", "test", 5, 1)
+	p.Kind = "ReplicaSet.v1.apps"
+	client := p.Client.(*MockKubeClient)
+
+	assert.Nil(t, p.ScaleUp())
+	assert.Equal(t, int32(4), client.Objects["replicasets"].Replicas)
+
+	_, err := p.ScaleTo(40)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(5), client.Objects["replicasets"].Replicas)
+
+	current, err := p.CurrentReplicas()
+	assert.Nil(t, err)
+	assert.Equal(t, 5, current)
+
+	assert.Nil(t, p.Annotate("example.com/key", "value"))
+	value, err := p.Annotation("example.com/key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+
+	// The deployment of the same name is left alone
+	assert.Equal(t, int32(3), client.Deployment().Replicas)
+}
+
+func TestScalesClient(t *testing.T) {
+	var patch map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/apis/argoproj.io/v1alpha1/namespaces/test/rollouts/worker/scale" && r.Method == "GET":
+			w.Write([]byte(`{
+				"apiVersion": "autoscaling/v1",
+				"kind": "Scale",
+				"metadata": {"name": "worker", "namespace": "test", "resourceVersion": "7"},
+				"spec": {"replicas": 2},
+				"status": {"replicas": 2}
+			}`))
+		case r.URL.Path == "/apis/argoproj.io/v1alpha1/namespaces/test/rollouts/worker/scale" && r.Method == "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+
+			var object map[string]interface{}
+			assert.Nil(t, json.Unmarshal(body, &object))
+			assert.Equal(t, "7", object["metadata"].(map[string]interface{})["resourceVersion"], "The read scale should be written back")
+
+			w.Write(body)
+		case r.URL.Path == "/apis/argoproj.io/v1alpha1/namespaces/test/rollouts/worker" && r.Method == "GET":
+			w.Write([]byte(`{"metadata": {"name": "worker", "annotations": {"example.com/key": "value"}}}`))
+		case r.URL.Path == "/apis/argoproj.io/v1alpha1/namespaces/test/rollouts/worker" && r.Method == "PATCH":
+			assert.Equal(t, "application/merge-patch+json", r.Header.Get("Content-Type"))
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Nil(t, json.Unmarshal(body, &patch))
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := kclient.New(&restclient.Config{Host: server.URL})
+	assert.Nil(t, err)
+	scales := (&kubeClient{c}).Scales(Resource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}, "test")
+
+	s, err := scales.Get("worker")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, s.Replicas)
+
+	s.Replicas = 3
+	s, err = scales.Update("worker", s)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, s.Replicas)
+
+	annotations, err := scales.Annotations("worker")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", annotations["example.com/key"])
+
+	assert.Nil(t, scales.Annotate("worker", "example.com/key", "other"))
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"example.com/key": "other"},
+		},
+	}, patch)
+}
+
+func TestScalesClientCoreGroup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/test/replicationcontrollers/worker/scale", r.URL.Path)
+		w.Write([]byte(`{"spec": {"replicas": 4}}`))
+	}))
+	defer server.Close()
+
+	c, err := kclient.New(&restclient.Config{Host: server.URL})
+	assert.Nil(t, err)
+
+	resource, err := Kind("ReplicationController.v1").Resource()
+	assert.Nil(t, err)
+
+	s, err := (&kubeClient{c}).Scales(resource, "test").Get("worker")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, s.Replicas)
+}
+
+func TestScalesClientConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{
+				"kind": "Status",
+				"apiVersion": "v1",
+				"status": "Failure",
+				"message": "the object has been modified",
+				"reason": "Conflict",
+				"code": 409
+			}`))
+			return
+		}
+
+		w.Write([]byte(`{"metadata": {"resourceVersion": "7"}, "spec": {"replicas": 2}}`))
+	}))
+	defer server.Close()
+
+	c, err := kclient.New(&restclient.Config{Host: server.URL})
+	assert.Nil(t, err)
+	scales := (&kubeClient{c}).Scales(Resource{Group: "apps", Version: "v1", Resource: "deployments"}, "test")
+
+	s, err := scales.Get("worker")
+	assert.Nil(t, err)
+
+	s.Replicas = 3
+	_, err = scales.Update("worker", s)
+	assert.True(t, apierrors.IsConflict(err), "Conflicts should be detected, got %v", err)
+}
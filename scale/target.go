@@ -0,0 +1,148 @@
+package scale
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Scale is the desired replicas of a target as read from the api server. To
+// change them, the same Scale is updated with new Replicas, so the rest of
+// what was read is written back unchanged.
+type Scale struct {
+	Replicas int
+
+	object interface{}
+}
+
+// ScaleTarget is a resource with replicas that the autoscaler scales.
+type ScaleTarget interface {
+	GetScale() (*Scale, error)
+	UpdateScale(scale *Scale) (*Scale, error)
+	Annotations() (map[string]string, error)
+	Annotate(key string, value string) error
+	Status() (*Status, error)
+}
+
+// Kind is the kind of resource an autoscaler scales: deployment, statefulset,
+// or any resource with a scale subresource given as Kind.version.group, e.g.
+// ReplicaSet.v1.apps or Rollout.v1alpha1.argoproj.io. Resources in the core
+// group leave out the group, e.g. ReplicationController.v1.
+type Kind string
+
+const (
+	KindDeployment  Kind = "deployment"
+	KindStatefulSet Kind = "statefulset"
+)
+
+func (k *Kind) String() string {
+	return string(*k)
+}
+
+// Set implements flag.Value.
+func (k *Kind) Set(v string) error {
+	if v == "" {
+		return errors.New("Invalid empty kind, expected deployment, statefulset or Kind.version.group")
+	}
+
+	if _, err := Kind(v).Resource(); err != nil {
+		return err
+	}
+
+	*k = Kind(v)
+	return nil
+}
+
+// UnmarshalJSON rejects invalid kinds.
+func (k *Kind) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	return k.Set(v)
+}
+
+// Resource is the api group, version and plural resource name of a kind.
+type Resource struct {
+	Group    string
+	Version  string
+	Resource string
+}
+
+// Resource returns the api resource of the kind. The resource name of a
+// Kind.version.group kind is the lower case plural of the kind.
+func (k Kind) Resource() (Resource, error) {
+	switch k {
+	case "", KindDeployment:
+		return Resource{Group: "apps", Version: "v1", Resource: "deployments"}, nil
+	case KindStatefulSet:
+		return Resource{Group: "apps", Version: "v1", Resource: "statefulsets"}, nil
+	}
+
+	parts := strings.SplitN(string(k), ".", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return Resource{}, errors.Errorf("Invalid kind %q, expected deployment, statefulset or Kind.version.group", string(k))
+	}
+
+	r := Resource{Version: parts[1], Resource: plural(strings.ToLower(parts[0]))}
+	if len(parts) == 3 {
+		r.Group = parts[2]
+	}
+
+	return r, nil
+}
+
+// Name returns the kind as the api server names it, e.g. Deployment.
+func (k Kind) Name() string {
+	switch k {
+	case "", KindDeployment:
+		return "Deployment"
+	case KindStatefulSet:
+		return "StatefulSet"
+	}
+
+	return strings.SplitN(string(k), ".", 2)[0]
+}
+
+// plural returns the plural of a lower case kind the way the api server
+// names resources.
+func plural(kind string) string {
+	switch {
+	case strings.HasSuffix(kind, "s"), strings.HasSuffix(kind, "x"),
+		strings.HasSuffix(kind, "ch"), strings.HasSuffix(kind, "sh"):
+		return kind + "es"
+	case strings.HasSuffix(kind, "y") && len(kind) > 1 && !strings.ContainsAny(kind[len(kind)-2:len(kind)-1], "aeiou"):
+		return kind[:len(kind)-1] + "ies"
+	}
+	return kind + "s"
+}
+
+// subresourceTarget scales a resource through its scale subresource, which
+// only writes the replicas and leaves the rest of the resource to its other
+// writers.
+type subresourceTarget struct {
+	client ScaleInterface
+	name   string
+}
+
+func (t *subresourceTarget) GetScale() (*Scale, error) {
+	return t.client.Get(t.name)
+}
+
+func (t *subresourceTarget) UpdateScale(scale *Scale) (*Scale, error) {
+	return t.client.Update(t.name, scale)
+}
+
+func (t *subresourceTarget) Annotations() (map[string]string, error) {
+	return t.client.Annotations(t.name)
+}
+
+func (t *subresourceTarget) Annotate(key string, value string) error {
+	return t.client.Annotate(t.name, key, value)
+}
+
+func (t *subresourceTarget) Status() (*Status, error) {
+	return t.client.Status(t.name)
+}
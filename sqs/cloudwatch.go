@@ -0,0 +1,149 @@
+package sqs
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/query"
+)
+
+// CloudWatch is the subset of the CloudWatch API used to read SQS metrics
+// that are not available as queue attributes.
+type CloudWatch interface {
+	GetMetricStatistics(*GetMetricStatisticsInput) (*GetMetricStatisticsOutput, error)
+}
+
+// latestMaximum returns the Maximum of the most recent datapoint of metric
+// for the queue within window, at one minute resolution. It returns false if
+// no datapoint was reported within window.
+func (s *SqsClient) latestMaximum(metric Metric, window time.Duration) (float64, bool, error) {
+	now := time.Now()
+
+	params := &GetMetricStatisticsInput{
+		Namespace:  aws.String(metric.Namespace),
+		MetricName: aws.String(metric.MetricName),
+		Dimensions: []*Dimension{
+			{
+				Name:  aws.String("QueueName"),
+				Value: aws.String(s.QueueName()),
+			},
+		},
+		StartTime:  aws.Time(now.Add(-window)),
+		EndTime:    aws.Time(now),
+		Period:     aws.Int64(60),
+		Statistics: []*string{aws.String("Maximum")},
+	}
+
+	out, err := s.CloudWatch.GetMetricStatistics(params)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var latest *Datapoint
+	for _, d := range out.Datapoints {
+		if d.Timestamp == nil || d.Maximum == nil {
+			continue
+		}
+		if latest == nil || d.Timestamp.After(*latest.Timestamp) {
+			latest = d
+		}
+	}
+
+	if latest == nil {
+		return 0, false, nil
+	}
+
+	return *latest.Maximum, true, nil
+}
+
+// The vendored aws-sdk-go only includes the SQS service, so the types below
+// mirror the shapes of service/cloudwatch for the single operation we need.
+
+type Dimension struct {
+	_ struct{} `type:"structure"`
+
+	Name  *string `min:"1" type:"string" required:"true"`
+	Value *string `min:"1" type:"string" required:"true"`
+}
+
+type GetMetricStatisticsInput struct {
+	_ struct{} `type:"structure"`
+
+	Dimensions []*Dimension `type:"list"`
+	EndTime    *time.Time   `type:"timestamp" timestampFormat:"iso8601" required:"true"`
+	MetricName *string      `min:"1" type:"string" required:"true"`
+	Namespace  *string      `min:"1" type:"string" required:"true"`
+	Period     *int64       `min:"60" type:"integer" required:"true"`
+	StartTime  *time.Time   `type:"timestamp" timestampFormat:"iso8601" required:"true"`
+	Statistics []*string    `min:"1" type:"list"`
+}
+
+type Datapoint struct {
+	_ struct{} `type:"structure"`
+
+	Average     *float64   `type:"double"`
+	Maximum     *float64   `type:"double"`
+	Minimum     *float64   `type:"double"`
+	SampleCount *float64   `type:"double"`
+	Sum         *float64   `type:"double"`
+	Timestamp   *time.Time `type:"timestamp" timestampFormat:"iso8601"`
+	Unit        *string    `type:"string"`
+}
+
+type GetMetricStatisticsOutput struct {
+	_ struct{} `type:"structure"`
+
+	Datapoints []*Datapoint `type:"list"`
+	Label      *string      `type:"string"`
+}
+
+// CloudWatchService is a CloudWatch API client speaking the query protocol.
+type CloudWatchService struct {
+	*client.Client
+}
+
+func NewCloudWatchService(p client.ConfigProvider, cfgs ...*aws.Config) *CloudWatchService {
+	c := p.ClientConfig("monitoring", cfgs...)
+
+	svc := &CloudWatchService{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   "monitoring",
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2010-08-01",
+			},
+			c.Handlers,
+		),
+	}
+
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(query.BuildHandler)
+	svc.Handlers.Unmarshal.PushBackNamed(query.UnmarshalHandler)
+	svc.Handlers.UnmarshalMeta.PushBackNamed(query.UnmarshalMetaHandler)
+	svc.Handlers.UnmarshalError.PushBackNamed(query.UnmarshalErrorHandler)
+
+	return svc
+}
+
+func (c *CloudWatchService) GetMetricStatistics(input *GetMetricStatisticsInput) (*GetMetricStatisticsOutput, error) {
+	op := &request.Operation{
+		Name:       "GetMetricStatistics",
+		HTTPMethod: "POST",
+		HTTPPath:   "/",
+	}
+
+	if input == nil {
+		input = &GetMetricStatisticsInput{}
+	}
+
+	output := &GetMetricStatisticsOutput{}
+	req := c.NewRequest(op, input, output)
+	err := req.Send()
+	return output, err
+}
@@ -0,0 +1,185 @@
+package sqs
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/pkg/errors"
+)
+
+// DeadLetters is a snapshot of how messages leave a queue: processed and
+// deleted by consumers, or moved to its dead-letter queue after failing too
+// many times.
+type DeadLetters struct {
+	// Found is false if no queue has a dead-letter queue.
+	Found bool
+	// Depth is the number of messages in the dead-letter queues.
+	Depth int
+	// Deleted is the number of messages deleted from the queues within the
+	// window.
+	Deleted int
+}
+
+// redrivePolicy is the RedrivePolicy attribute of a queue.
+type redrivePolicy struct {
+	DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	MaxReceiveCount     int    `json:"maxReceiveCount"`
+}
+
+// DeadLetterQueueUrl returns the url of the queue's dead-letter queue from
+// its RedrivePolicy, or an empty string if it has none. Once found, the url
+// is remembered.
+func (s *SqsClient) DeadLetterQueueUrl() (string, error) {
+	if s.deadLetterQueueUrl != "" {
+		return s.deadLetterQueueUrl, nil
+	}
+
+	params := &sqs.GetQueueAttributesInput{
+		AttributeNames: []*string{aws.String("RedrivePolicy")},
+		QueueUrl:       aws.String(s.QueueUrl),
+	}
+
+	out, err := s.Client.GetQueueAttributes(params)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to get redrive policy of queue")
+	}
+
+	value, ok := out.Attributes["RedrivePolicy"]
+	if !ok || value == nil {
+		return "", nil
+	}
+
+	var policy redrivePolicy
+	if err := json.Unmarshal([]byte(*value), &policy); err != nil {
+		return "", errors.Wrap(err, "Failed to parse redrive policy of queue")
+	}
+
+	url, err := siblingQueueUrl(s.QueueUrl, policy.DeadLetterTargetArn)
+	if err != nil {
+		return "", err
+	}
+
+	s.deadLetterQueueUrl = url
+	return url, nil
+}
+
+// siblingQueueUrl returns the url of the queue with the arn in the form
+// arn:partition:sqs:region:account:name, which is in the same account and
+// region as the queue at url. Its url is that of the queue with the name
+// replaced, which also holds in other partitions and for custom endpoints.
+func siblingQueueUrl(url string, arn string) (string, error) {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sqs" {
+		return "", errors.Errorf("Invalid queue arn %q", arn)
+	}
+
+	i := strings.LastIndex(url, "/")
+	if i < 0 {
+		return "", errors.Errorf("Invalid queue url %q", url)
+	}
+
+	return url[:i+1] + parts[5], nil
+}
+
+// DeadLetters fetches the depth of the queue's dead-letter queue and the
+// NumberOfMessagesDeleted reported to CloudWatch for the queue within window.
+// A dead-letter queue is in the same account and region as its source queue,
+// so it is read with the same client.
+func (s *SqsClient) DeadLetters(window time.Duration) (DeadLetters, error) {
+	url, err := s.DeadLetterQueueUrl()
+	if err != nil {
+		return DeadLetters{}, err
+	}
+
+	var deadLetters DeadLetters
+
+	if url != "" {
+		params := &sqs.GetQueueAttributesInput{
+			AttributeNames: []*string{aws.String("ApproximateNumberOfMessages")},
+			QueueUrl:       aws.String(url),
+		}
+
+		out, err := s.Client.GetQueueAttributes(params)
+		if err != nil {
+			return DeadLetters{}, errors.Wrap(err, "Failed to get messages in dead-letter queue")
+		}
+
+		if value, ok := out.Attributes["ApproximateNumberOfMessages"]; ok && value != nil {
+			if deadLetters.Depth, err = strconv.Atoi(*value); err != nil {
+				return DeadLetters{}, errors.Wrap(err, "Failed to parse messages in dead-letter queue")
+			}
+		}
+
+		deadLetters.Found = true
+	}
+
+	if deadLetters.Deleted, err = s.messagesDeleted(window); err != nil {
+		return DeadLetters{}, err
+	}
+
+	return deadLetters, nil
+}
+
+// messagesDeleted returns the sum of NumberOfMessagesDeleted for the queue
+// within window, rounded up to whole minutes.
+func (s *SqsClient) messagesDeleted(window time.Duration) (int, error) {
+	now := time.Now()
+
+	period := int64((window + time.Minute - 1) / time.Minute * 60)
+	if period < 60 {
+		period = 60
+	}
+
+	params := &GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/SQS"),
+		MetricName: aws.String("NumberOfMessagesDeleted"),
+		Dimensions: []*Dimension{
+			{
+				Name:  aws.String("QueueName"),
+				Value: aws.String(s.QueueName()),
+			},
+		},
+		StartTime:  aws.Time(now.Add(-time.Duration(period) * time.Second)),
+		EndTime:    aws.Time(now),
+		Period:     aws.Int64(period),
+		Statistics: []*string{aws.String("Sum")},
+	}
+
+	out, err := s.CloudWatch.GetMetricStatistics(params)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to get deleted messages from CloudWatch")
+	}
+
+	var deleted float64
+	for _, d := range out.Datapoints {
+		if d.Sum != nil {
+			deleted += *d.Sum
+		}
+	}
+
+	return int(deleted), nil
+}
+
+// DeadLetters sums the dead-letter queue depths and deleted messages of all
+// queues.
+func (m *MultiQueue) DeadLetters(window time.Duration) (DeadLetters, error) {
+	var total DeadLetters
+
+	for _, q := range m.Queues {
+		deadLetters, err := q.Queue.DeadLetters(window)
+		if err != nil {
+			return DeadLetters{}, err
+		}
+
+		total.Found = total.Found || deadLetters.Found
+		total.Depth += deadLetters.Depth
+		total.Deleted += deadLetters.Deleted
+	}
+
+	return total, nil
+}
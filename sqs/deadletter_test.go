@@ -0,0 +1,104 @@
+package sqs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockRedriveSQS serves the attributes of several queues by url.
+type MockRedriveSQS struct {
+	Queues map[string]map[string]*string
+}
+
+func (m *MockRedriveSQS) GetQueueAttributes(input *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{Attributes: m.Queues[*input.QueueUrl]}, nil
+}
+
+func (m *MockRedriveSQS) SetQueueAttributes(*sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+func NewMockRedriveSqsClient() *SqsClient {
+	return &SqsClient{
+		Client: &MockRedriveSQS{
+			Queues: map[string]map[string]*string{
+				"https://sqs.us-east-1.amazonaws.com/123456789012/jobs": {
+					"ApproximateNumberOfMessages": aws.String("500"),
+					"RedrivePolicy":               aws.String(`{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:jobs-dlq","maxReceiveCount":5}`),
+				},
+				"https://sqs.us-east-1.amazonaws.com/123456789012/jobs-dlq": {
+					"ApproximateNumberOfMessages": aws.String("42"),
+				},
+			},
+		},
+		CloudWatch: &MockCloudWatch{
+			Datapoints: []*Datapoint{
+				{Timestamp: aws.Time(time.Now()), Sum: aws.Float64(300)},
+				{Timestamp: aws.Time(time.Now()), Sum: aws.Float64(100)},
+			},
+		},
+		QueueUrl: "https://sqs.us-east-1.amazonaws.com/123456789012/jobs",
+	}
+}
+
+func TestDeadLetterQueueUrl(t *testing.T) {
+	s := NewMockRedriveSqsClient()
+
+	url, err := s.DeadLetterQueueUrl()
+	assert.Nil(t, err)
+	assert.Equal(t, "https://sqs.us-east-1.amazonaws.com/123456789012/jobs-dlq", url)
+
+	s = NewMockSqsClient()
+	url, err = s.DeadLetterQueueUrl()
+	assert.Nil(t, err)
+	assert.Equal(t, "", url, "A queue without a redrive policy has no dead-letter queue")
+}
+
+func TestSiblingQueueUrl(t *testing.T) {
+	url, err := siblingQueueUrl("https://sqs.cn-north-1.amazonaws.com.cn/123456789012/jobs", "arn:aws-cn:sqs:cn-north-1:123456789012:jobs-dlq")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://sqs.cn-north-1.amazonaws.com.cn/123456789012/jobs-dlq", url)
+
+	url, err = siblingQueueUrl("http://localhost:4566/000000000000/jobs", "arn:aws:sqs:us-east-1:000000000000:jobs-dlq")
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:4566/000000000000/jobs-dlq", url, "Custom endpoints should be kept")
+}
+
+func TestDeadLetterQueueUrlInvalid(t *testing.T) {
+	s := NewMockSqsClient()
+
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"RedrivePolicy": aws.String(`{"deadLetterTargetArn":`)},
+	})
+	_, err := s.DeadLetterQueueUrl()
+	assert.NotNil(t, err)
+
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"RedrivePolicy": aws.String(`{"deadLetterTargetArn":"jobs-dlq"}`)},
+	})
+	_, err = s.DeadLetterQueueUrl()
+	assert.NotNil(t, err)
+}
+
+func TestDeadLetters(t *testing.T) {
+	s := NewMockRedriveSqsClient()
+
+	deadLetters, err := s.DeadLetters(5 * time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, DeadLetters{Found: true, Depth: 42, Deleted: 400}, deadLetters)
+
+	m := &MultiQueue{
+		Queues: []WeightedQueue{
+			{Queue: s, Weight: 1},
+			{Queue: NewMockSqsClient(), Weight: 1},
+		},
+	}
+
+	deadLetters, err = m.DeadLetters(5 * time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, DeadLetters{Found: true, Depth: 42, Deleted: 400}, deadLetters)
+}
@@ -0,0 +1,104 @@
+package sqs
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/pkg/errors"
+)
+
+// Metric is a CloudWatch metric published by the consumers of a queue with a
+// QueueName dimension.
+type Metric struct {
+	Namespace  string
+	MetricName string
+}
+
+// ParseMetric parses a metric in the form namespace/name, where the
+// namespace may itself contain slashes.
+func ParseMetric(s string) (Metric, error) {
+	i := strings.LastIndex(s, "/")
+	if i <= 0 || i == len(s)-1 {
+		return Metric{}, errors.Errorf("Invalid metric %q, expected namespace/name", s)
+	}
+
+	return Metric{Namespace: s[:i], MetricName: s[i+1:]}, nil
+}
+
+func (m Metric) String() string {
+	if m == (Metric{}) {
+		return ""
+	}
+	return m.Namespace + "/" + m.MetricName
+}
+
+// Fifo returns true if the queue is a FIFO queue, from its FifoQueue
+// attribute. The attribute never changes, so it is only fetched once.
+func (s *SqsClient) Fifo() (bool, error) {
+	if s.fifo != nil {
+		return *s.fifo, nil
+	}
+
+	params := &sqs.GetQueueAttributesInput{
+		AttributeNames: []*string{aws.String("FifoQueue")},
+		QueueUrl:       aws.String(s.QueueUrl),
+	}
+
+	out, err := s.Client.GetQueueAttributes(params)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to get FifoQueue attribute of queue")
+	}
+
+	value, ok := out.Attributes["FifoQueue"]
+	fifo := ok && value != nil && *value == "true"
+
+	s.fifo = &fifo
+	return fifo, nil
+}
+
+// ActiveMessageGroups returns the number of message groups with messages in
+// a FIFO queue, as reported by its consumers in MessageGroupsMetric. Only one
+// consumer can work on a message group at a time, so this is the most
+// consumers that can be busy. It returns false if the queue is not FIFO, no
+// metric is configured or no datapoint was reported within five minutes.
+//
+// Groups are not sampled by receiving messages, since receiving counts
+// towards the redrive policy's maxReceiveCount and locks the group.
+func (s *SqsClient) ActiveMessageGroups() (int, bool, error) {
+	if s.MessageGroupsMetric == (Metric{}) {
+		return 0, false, nil
+	}
+
+	fifo, err := s.Fifo()
+	if err != nil || !fifo {
+		return 0, false, err
+	}
+
+	groups, ok, err := s.latestMaximum(s.MessageGroupsMetric, 5*time.Minute)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "Failed to get active message groups from CloudWatch")
+	}
+
+	return int(groups), ok, nil
+}
+
+// ActiveMessageGroups returns the total active message groups of all queues.
+// A standard queue, or one without a known group count, can use any number
+// of consumers, so the total is only known when it is known for every queue.
+func (m *MultiQueue) ActiveMessageGroups() (int, bool, error) {
+	var total int
+
+	for _, q := range m.Queues {
+		groups, ok, err := q.Queue.ActiveMessageGroups()
+		if err != nil || !ok {
+			return 0, false, err
+		}
+
+		total += groups
+	}
+
+	return total, len(m.Queues) > 0, nil
+}
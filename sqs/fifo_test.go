@@ -0,0 +1,92 @@
+package sqs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func NewMockFifoSqsClient(groups float64) *SqsClient {
+	s := NewMockSqsClient()
+	s.QueueUrl = "https://sqs.us-east-1.amazonaws.com/123456789012/orders.fifo"
+	s.MessageGroupsMetric = Metric{Namespace: "Orders/Consumers", MetricName: "ActiveMessageGroups"}
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"FifoQueue": aws.String("true")},
+	})
+	s.CloudWatch = &MockCloudWatch{
+		Datapoints: []*Datapoint{
+			{Timestamp: aws.Time(time.Now().Add(-2 * time.Minute)), Maximum: aws.Float64(12)},
+			{Timestamp: aws.Time(time.Now().Add(-1 * time.Minute)), Maximum: aws.Float64(groups)},
+		},
+	}
+
+	return s
+}
+
+func TestParseMetric(t *testing.T) {
+	metric, err := ParseMetric("Orders/Consumers/ActiveMessageGroups")
+	assert.Nil(t, err)
+	assert.Equal(t, Metric{Namespace: "Orders/Consumers", MetricName: "ActiveMessageGroups"}, metric)
+	assert.Equal(t, "Orders/Consumers/ActiveMessageGroups", metric.String())
+
+	for _, s := range []string{"", "ActiveMessageGroups", "/ActiveMessageGroups", "Orders/"} {
+		_, err := ParseMetric(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestFifo(t *testing.T) {
+	s := NewMockFifoSqsClient(4)
+
+	fifo, err := s.Fifo()
+	assert.Nil(t, err)
+	assert.True(t, fifo)
+
+	fifo, err = NewMockSqsClient().Fifo()
+	assert.Nil(t, err)
+	assert.False(t, fifo)
+}
+
+func TestActiveMessageGroups(t *testing.T) {
+	s := NewMockFifoSqsClient(4)
+
+	groups, ok, err := s.ActiveMessageGroups()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 4, groups, "The latest datapoint should be used")
+
+	// A standard queue has no limit on consumers
+	standard := NewMockSqsClient()
+	standard.MessageGroupsMetric = s.MessageGroupsMetric
+	_, ok, err = standard.ActiveMessageGroups()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// Neither does a queue whose consumers stopped reporting
+	s.CloudWatch = &MockCloudWatch{}
+	_, ok, err = s.ActiveMessageGroups()
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestMultiQueueActiveMessageGroups(t *testing.T) {
+	m := &MultiQueue{
+		Queues: []WeightedQueue{
+			{Queue: NewMockFifoSqsClient(4), Weight: 1},
+			{Queue: NewMockFifoSqsClient(3), Weight: 1},
+		},
+	}
+
+	groups, ok, err := m.ActiveMessageGroups()
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 7, groups)
+
+	m.Queues = append(m.Queues, WeightedQueue{Queue: NewMockSqsClient(), Weight: 1})
+	_, ok, err = m.ActiveMessageGroups()
+	assert.Nil(t, err)
+	assert.False(t, ok, "A standard queue removes the cap")
+}
@@ -0,0 +1,114 @@
+package sqs
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Aggregation combines the backlogs of several queues into one.
+type Aggregation string
+
+const (
+	// Sum adds the backlogs of all queues.
+	Sum Aggregation = "sum"
+	// Max takes the largest backlog of any queue.
+	Max Aggregation = "max"
+	// WeightedSum adds the backlogs of all queues multiplied by their weight.
+	WeightedSum Aggregation = "weighted-sum"
+)
+
+func (a *Aggregation) String() string {
+	return string(*a)
+}
+
+// Set implements flag.Value.
+func (a *Aggregation) Set(v string) error {
+	switch Aggregation(v) {
+	case Sum, Max, WeightedSum:
+		*a = Aggregation(v)
+		return nil
+	}
+
+	return errors.Errorf("Invalid aggregation %q, expected sum, max or weighted-sum", v)
+}
+
+// UnmarshalJSON rejects unknown aggregations.
+func (a *Aggregation) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	return a.Set(v)
+}
+
+// WeightedQueue is a queue with its weight in a weighted sum.
+type WeightedQueue struct {
+	Queue  Queue
+	Weight float64
+}
+
+// MultiQueue aggregates the backlog of several queues, possibly in different
+// regions, that are consumed by the same deployment.
+type MultiQueue struct {
+	Queues      []WeightedQueue
+	Aggregation Aggregation
+}
+
+// Backlog fetches the backlog of every queue. The message counts in
+// Attributes are always summed so an idle target is one where every queue is
+// empty, while Messages is combined using the Aggregation.
+func (m *MultiQueue) Backlog() (Backlog, error) {
+	var total Backlog
+	var weighted float64
+
+	for _, q := range m.Queues {
+		backlog, err := q.Queue.Backlog()
+		if err != nil {
+			return Backlog{}, err
+		}
+
+		total.Attributes.Visible += backlog.Attributes.Visible
+		total.Attributes.InFlight += backlog.Attributes.InFlight
+		total.Attributes.Delayed += backlog.Attributes.Delayed
+
+		switch m.Aggregation {
+		case Max:
+			if backlog.Messages > total.Messages {
+				total.Messages = backlog.Messages
+			}
+		case WeightedSum:
+			weighted += q.Weight * float64(backlog.Messages)
+		default:
+			total.Messages += backlog.Messages
+		}
+	}
+
+	if m.Aggregation == WeightedSum {
+		total.Messages = int(math.Ceil(weighted))
+	}
+
+	return total, nil
+}
+
+// AgeOfOldestMessage returns the largest age of the oldest message of any of
+// the queues.
+func (m *MultiQueue) AgeOfOldestMessage() (time.Duration, error) {
+	var oldest time.Duration
+
+	for _, q := range m.Queues {
+		age, err := q.Queue.AgeOfOldestMessage()
+		if err != nil {
+			return 0, err
+		}
+
+		if age > oldest {
+			oldest = age
+		}
+	}
+
+	return oldest, nil
+}
@@ -0,0 +1,60 @@
+package sqs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockQueue(visible string, inFlight string, age float64) *SqsClient {
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{
+			"ApproximateNumberOfMessages":           aws.String(visible),
+			"ApproximateNumberOfMessagesNotVisible": aws.String(inFlight),
+		},
+	})
+	s.CloudWatch = &MockCloudWatch{
+		Datapoints: []*Datapoint{{Timestamp: aws.Time(time.Now()), Maximum: aws.Float64(age)}},
+	}
+	return s
+}
+
+func TestMultiQueueBacklog(t *testing.T) {
+	m := &MultiQueue{
+		Queues: []WeightedQueue{
+			{Queue: newMockQueue("100", "5", 30), Weight: 2},
+			{Queue: newMockQueue("40", "0", 90), Weight: 0.5},
+		},
+	}
+
+	backlog, err := m.Backlog()
+	assert.Nil(t, err)
+	assert.Equal(t, 140, backlog.Messages)
+	assert.Equal(t, QueueAttributes{Visible: 140, InFlight: 5}, backlog.Attributes)
+
+	m.Aggregation = Max
+	backlog, err = m.Backlog()
+	assert.Nil(t, err)
+	assert.Equal(t, 100, backlog.Messages)
+
+	m.Aggregation = WeightedSum
+	backlog, err = m.Backlog()
+	assert.Nil(t, err)
+	assert.Equal(t, 220, backlog.Messages)
+
+	age, err := m.AgeOfOldestMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, 90*time.Second, age)
+}
+
+func TestAggregationFlag(t *testing.T) {
+	var a Aggregation
+
+	assert.Nil(t, a.Set("weighted-sum"))
+	assert.Equal(t, WeightedSum, a)
+	assert.NotNil(t, a.Set("average"))
+}
@@ -1,7 +1,10 @@
 package sqs
 
 import (
+	"math"
+	"path"
 	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -16,34 +19,162 @@ type SQS interface {
 	SetQueueAttributes(*sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error)
 }
 
+// Queue is a source of backlog for the autoscaler, either a single SQS queue
+// or several aggregated together.
+type Queue interface {
+	Backlog() (Backlog, error)
+	AgeOfOldestMessage() (time.Duration, error)
+	DeadLetters(window time.Duration) (DeadLetters, error)
+	ActiveMessageGroups() (int, bool, error)
+}
+
+// Backlog is a snapshot of the messages waiting in a queue.
+type Backlog struct {
+	// Attributes are the raw message counts.
+	Attributes QueueAttributes
+	// Messages is the backlog used for scaling decisions.
+	Messages int
+}
+
 type SqsClient struct {
-	Client   SQS
-	QueueUrl string
+	Client     SQS
+	CloudWatch CloudWatch
+	QueueUrl   string
+	Weights    Weights
+
+	// MessageGroupsMetric is the metric the consumers of a FIFO queue
+	// publish the number of active message groups to.
+	MessageGroupsMetric Metric
+
+	deadLetterQueueUrl string
+	fifo               *bool
 }
 
 func NewSqsClient(queue string, region string) *SqsClient {
-	svc := sqs.New(session.New(), &aws.Config{Region: aws.String(region)})
+	sess := session.New()
+	config := &aws.Config{Region: aws.String(region)}
+
 	return &SqsClient{
-		svc,
-		queue,
+		Client:     sqs.New(sess, config),
+		CloudWatch: NewCloudWatchService(sess, config),
+		QueueUrl:   queue,
 	}
 }
 
-func (s *SqsClient) NumMessages() (int, error) {
+// QueueName returns the name of the queue, the last path segment of its url.
+func (s *SqsClient) QueueName() string {
+	return path.Base(s.QueueUrl)
+}
+
+// QueueAttributes are the approximate message counts of a queue.
+type QueueAttributes struct {
+	// Visible messages are available for retrieval.
+	Visible int
+	// InFlight messages have been received by a consumer but not yet deleted.
+	InFlight int
+	// Delayed messages are not yet available for retrieval.
+	Delayed int
+}
+
+// Weights configure how much each kind of message counts towards the backlog.
+type Weights struct {
+	Visible  float64
+	InFlight float64
+	Delayed  float64
+}
+
+// DefaultWeights count only visible messages.
+var DefaultWeights = Weights{Visible: 1}
+
+// Empty reports whether the queue has no visible, in-flight or delayed
+// messages.
+func (q QueueAttributes) Empty() bool {
+	return q.Visible == 0 && q.InFlight == 0 && q.Delayed == 0
+}
+
+// Backlog returns the weighted sum of the message counts, rounded up.
+func (q QueueAttributes) Backlog(w Weights) int {
+	backlog := w.Visible*float64(q.Visible) + w.InFlight*float64(q.InFlight) + w.Delayed*float64(q.Delayed)
+	return int(math.Ceil(backlog))
+}
+
+// QueueAttributes fetches the visible, in-flight and delayed message counts
+// of the queue in a single call.
+func (s *SqsClient) QueueAttributes() (QueueAttributes, error) {
 	params := &sqs.GetQueueAttributesInput{
-		AttributeNames: []*string{aws.String("ApproximateNumberOfMessages")},
-		QueueUrl:       aws.String(s.QueueUrl),
+		AttributeNames: []*string{
+			aws.String("ApproximateNumberOfMessages"),
+			aws.String("ApproximateNumberOfMessagesNotVisible"),
+			aws.String("ApproximateNumberOfMessagesDelayed"),
+		},
+		QueueUrl: aws.String(s.QueueUrl),
 	}
 
 	out, err := s.Client.GetQueueAttributes(params)
 	if err != nil {
-		return 0, errors.Wrap(err, "Failed to get messages in SQS")
+		return QueueAttributes{}, errors.Wrap(err, "Failed to get messages in SQS")
 	}
 
-	messages, err := strconv.Atoi(*out.Attributes["ApproximateNumberOfMessages"])
+	var attributes QueueAttributes
+	counts := map[string]*int{
+		"ApproximateNumberOfMessages":           &attributes.Visible,
+		"ApproximateNumberOfMessagesNotVisible": &attributes.InFlight,
+		"ApproximateNumberOfMessagesDelayed":    &attributes.Delayed,
+	}
+
+	for name, count := range counts {
+		value, ok := out.Attributes[name]
+		if !ok || value == nil {
+			continue
+		}
+
+		*count, err = strconv.Atoi(*value)
+		if err != nil {
+			return QueueAttributes{}, errors.Wrapf(err, "Failed to parse %s of queue", name)
+		}
+	}
+
+	return attributes, nil
+}
+
+// NumMessages returns the backlog of the queue weighted by Weights.
+func (s *SqsClient) NumMessages() (int, error) {
+	backlog, err := s.Backlog()
 	if err != nil {
 		return 0, errors.Wrap(err, "Failed to get number of messages in queue")
 	}
 
-	return messages, nil
+	return backlog.Messages, nil
+}
+
+// Backlog fetches the message counts of the queue and weights them by
+// Weights. A zero Weights counts only visible messages.
+func (s *SqsClient) Backlog() (Backlog, error) {
+	attributes, err := s.QueueAttributes()
+	if err != nil {
+		return Backlog{}, err
+	}
+
+	weights := s.Weights
+	if weights == (Weights{}) {
+		weights = DefaultWeights
+	}
+
+	return Backlog{
+		Attributes: attributes,
+		Messages:   attributes.Backlog(weights),
+	}, nil
+}
+
+// AgeOfOldestMessage returns the most recent ApproximateAgeOfOldestMessage
+// reported to CloudWatch for the queue. SQS only publishes the metric at one
+// minute resolution, so the last five minutes are searched for a datapoint.
+// A queue without recent datapoints is reported as having no old messages.
+func (s *SqsClient) AgeOfOldestMessage() (time.Duration, error) {
+	age, _, err := s.latestMaximum(Metric{Namespace: "AWS/SQS", MetricName: "ApproximateAgeOfOldestMessage"}, 5*time.Minute)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to get age of oldest message from CloudWatch")
+	}
+
+	return time.Duration(age * float64(time.Second)), nil
 }
@@ -1,9 +1,14 @@
 package sqs
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +21,111 @@ func TestNumMessages(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestQueueAttributes(t *testing.T) {
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{
+			"ApproximateNumberOfMessages":           aws.String("50"),
+			"ApproximateNumberOfMessagesNotVisible": aws.String("20"),
+			"ApproximateNumberOfMessagesDelayed":    aws.String("5"),
+		},
+	})
+
+	attributes, err := s.QueueAttributes()
+	assert.Nil(t, err)
+	assert.Equal(t, QueueAttributes{Visible: 50, InFlight: 20, Delayed: 5}, attributes)
+
+	// Only visible messages count by default
+	num, err := s.NumMessages()
+	assert.Nil(t, err)
+	assert.Equal(t, 50, num)
+
+	s.Weights = Weights{Visible: 1, InFlight: 1, Delayed: 0.5}
+	num, err = s.NumMessages()
+	assert.Nil(t, err)
+	assert.Equal(t, 73, num)
+}
+
+func TestQueueAttributesInvalid(t *testing.T) {
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{
+			"ApproximateNumberOfMessagesNotVisible": aws.String("many"),
+		},
+	})
+
+	_, err := s.QueueAttributes()
+	assert.NotNil(t, err)
+}
+
+func TestAgeOfOldestMessage(t *testing.T) {
+	s := NewMockSqsClient()
+
+	age, err := s.AgeOfOldestMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, 150*time.Second, age)
+
+	s.CloudWatch = &MockCloudWatch{}
+	age, err = s.AgeOfOldestMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), age)
+}
+
+func TestQueueName(t *testing.T) {
+	s := &SqsClient{QueueUrl: "https://sqs.us-east-1.amazonaws.com/123456789012/my-queue"}
+	assert.Equal(t, "my-queue", s.QueueName())
+}
+
+func TestCloudWatchServiceGetMetricStatistics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		assert.Equal(t, "GetMetricStatistics", r.Form.Get("Action"))
+		assert.Equal(t, "AWS/SQS", r.Form.Get("Namespace"))
+		assert.Equal(t, "QueueName", r.Form.Get("Dimensions.member.1.Name"))
+		assert.Equal(t, "my-queue", r.Form.Get("Dimensions.member.1.Value"))
+		assert.Equal(t, "Maximum", r.Form.Get("Statistics.member.1"))
+
+		w.Write([]byte(`<GetMetricStatisticsResponse xmlns="http://monitoring.amazonaws.com/doc/2010-08-01/">
+  <GetMetricStatisticsResult>
+    <Datapoints>
+      <member>
+        <Timestamp>2016-08-01T12:00:00Z</Timestamp>
+        <Maximum>42.0</Maximum>
+        <Unit>Seconds</Unit>
+      </member>
+    </Datapoints>
+    <Label>ApproximateAgeOfOldestMessage</Label>
+  </GetMetricStatisticsResult>
+</GetMetricStatisticsResponse>`))
+	}))
+	defer server.Close()
+
+	svc := NewCloudWatchService(session.New(), &aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+
+	s := &SqsClient{
+		CloudWatch: svc,
+		QueueUrl:   "https://sqs.us-east-1.amazonaws.com/123456789012/my-queue",
+	}
+
+	age, err := s.AgeOfOldestMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, 42*time.Second, age)
+}
+
+type MockCloudWatch struct {
+	Datapoints []*Datapoint
+}
+
+func (m *MockCloudWatch) GetMetricStatistics(*GetMetricStatisticsInput) (*GetMetricStatisticsOutput, error) {
+	return &GetMetricStatisticsOutput{
+		Datapoints: m.Datapoints,
+	}, nil
+}
+
 type MockSQS struct {
 	QueueAttributes *sqs.GetQueueAttributesOutput
 }
@@ -35,6 +145,7 @@ func (m *MockSQS) SetQueueAttributes(input *sqs.SetQueueAttributesInput) (*sqs.S
 func NewMockSqsClient() *SqsClient {
 	Attributes := make(map[string]*string)
 	Attributes["ApproximateNumberOfMessages"] = aws.String("50")
+	now := time.Now()
 
 	return &SqsClient{
 		Client: &MockSQS{
@@ -42,6 +153,12 @@ func NewMockSqsClient() *SqsClient {
 				Attributes: Attributes,
 			},
 		},
+		CloudWatch: &MockCloudWatch{
+			Datapoints: []*Datapoint{
+				{Timestamp: aws.Time(now.Add(-2 * time.Minute)), Maximum: aws.Float64(90)},
+				{Timestamp: aws.Time(now.Add(-1 * time.Minute)), Maximum: aws.Float64(150)},
+			},
+		},
 		QueueUrl: "example.com",
 	}
 }
@@ -0,0 +1,1161 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+
+	log "github.com/Sirupsen/logrus"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+
+	"github.com/Wattpad/kube-sqs-autoscaler/policy"
+	"github.com/Wattpad/kube-sqs-autoscaler/scale"
+	"github.com/Wattpad/kube-sqs-autoscaler/sqs"
+)
+
+// Target is a deployment scaled on the backlog of its queues, with its own
+// thresholds, cool downs and scaling policy. The command line flags describe
+// a single target and act as the defaults for every target in a config file.
+type Target struct {
+	Name       string     `json:"name"`
+	Deployment string     `json:"deployment"`
+	Kind       scale.Kind `json:"kind"`
+	Namespace  string     `json:"namespace"`
+	MinPods    int        `json:"minPods"`
+	MaxPods    int        `json:"maxPods"`
+
+	Queues           []QueueConfig   `json:"queues"`
+	QueueAggregation sqs.Aggregation `json:"queueAggregation"`
+	VisibleWeight    float64         `json:"visibleWeight"`
+	InFlightWeight   float64         `json:"inFlightWeight"`
+	DelayedWeight    float64         `json:"delayedWeight"`
+
+	MessageGroupsMetric string `json:"messageGroupsMetric"`
+
+	PollInterval      unversioned.Duration `json:"pollInterval"`
+	ScaleUpCoolDown   unversioned.Duration `json:"scaleUpCoolDown"`
+	ScaleDownCoolDown unversioned.Duration `json:"scaleDownCoolDown"`
+	ScaleUpMessages   int                  `json:"scaleUpMessages"`
+	ScaleDownMessages int                  `json:"scaleDownMessages"`
+
+	TargetMessagesPerPod int                `json:"targetMessagesPerPod"`
+	ScaleSteps           policy.StepPolicy  `json:"scaleSteps"`
+	PID                  PIDConfig          `json:"pid"`
+	Predictive           PredictiveConfig   `json:"predictive"`
+	Rate                 RateConfig         `json:"rate"`
+	Throughput           ThroughputConfig   `json:"throughput"`
+	Conditioning         ConditioningConfig `json:"conditioning"`
+
+	MaxMessageAge      unversioned.Duration `json:"maxMessageAge"`
+	DeadLetter         DeadLetterConfig     `json:"deadLetter"`
+	ScaleToZeroAfter   unversioned.Duration `json:"scaleToZeroAfter"`
+	ActivationReplicas int                  `json:"activationReplicas"`
+	ReadinessTimeout   unversioned.Duration `json:"readinessTimeout"`
+
+	ScaleUpStabilization   unversioned.Duration `json:"scaleUpStabilization"`
+	ScaleDownStabilization unversioned.Duration `json:"scaleDownStabilization"`
+	ScaleUpLimits          scale.RateLimits     `json:"scaleUpLimits"`
+	ScaleDownLimits        scale.RateLimits     `json:"scaleDownLimits"`
+	ScaleUpSelect          scale.SelectPolicy   `json:"scaleUpSelect"`
+	ScaleDownSelect        scale.SelectPolicy   `json:"scaleDownSelect"`
+
+	Schedules policy.Schedules `json:"schedules"`
+	Budget    BudgetConfig     `json:"budget"`
+
+	Competition CompetitionConfig `json:"competition"`
+
+	Job JobConfig `json:"job"`
+}
+
+// QueueConfig is one of the queues consumed by a target.
+type QueueConfig struct {
+	URL    string  `json:"url"`
+	Region string  `json:"region"`
+	Weight float64 `json:"weight"`
+}
+
+// UnmarshalJSON defaults the weight of a queue to 1, as for --sqs-queue.
+func (q *QueueConfig) UnmarshalJSON(data []byte) error {
+	type queueConfig QueueConfig
+	c := queueConfig{Weight: 1}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+
+	*q = QueueConfig(c)
+	return nil
+}
+
+type PIDConfig struct {
+	Enabled       bool    `json:"enabled"`
+	Setpoint      int     `json:"setpoint"`
+	Kp            float64 `json:"kp"`
+	Ki            float64 `json:"ki"`
+	Kd            float64 `json:"kd"`
+	IntegralLimit float64 `json:"integralLimit"`
+}
+
+type PredictiveConfig struct {
+	Model            string               `json:"model"`
+	PodStartupTime   unversioned.Duration `json:"podStartupTime"`
+	Window           unversioned.Duration `json:"window"`
+	Season           unversioned.Duration `json:"season"`
+	HoltWintersAlpha float64              `json:"holtWintersAlpha"`
+	HoltWintersBeta  float64              `json:"holtWintersBeta"`
+	HoltWintersGamma float64              `json:"holtWintersGamma"`
+}
+
+type RateConfig struct {
+	Enabled   bool                 `json:"enabled"`
+	Window    unversioned.Duration `json:"window"`
+	DrainTime unversioned.Duration `json:"drainTime"`
+}
+
+// ConditioningConfig smooths the backlog before it is used for scaling
+// decisions, and requires a scaling direction on ConfirmPolls consecutive
+// polls before acting on it.
+type ConditioningConfig struct {
+	Smoothing     string  `json:"smoothing"`
+	EWMAAlpha     float64 `json:"ewmaAlpha"`
+	MedianSamples int     `json:"medianSamples"`
+	ConfirmPolls  int     `json:"confirmPolls"`
+}
+
+type ThroughputConfig struct {
+	Enabled   bool                 `json:"enabled"`
+	Window    unversioned.Duration `json:"window"`
+	Smoothing float64              `json:"smoothing"`
+	DrainTime unversioned.Duration `json:"drainTime"`
+}
+
+type DeadLetterConfig struct {
+	MaxRatio float64              `json:"maxRatio"`
+	Window   unversioned.Duration `json:"window"`
+}
+
+// CompetitionConfig decides what a target does when the replicas are changed
+// by someone else, or a HorizontalPodAutoscaler or another autoscaler
+// instance scales the same resource. Nothing is checked without a policy.
+type CompetitionConfig struct {
+	Policy      policy.CompetitionPolicy `json:"policy"`
+	YieldPeriod unversioned.Duration     `json:"yieldPeriod"`
+}
+
+// JobConfig runs the backlog as Jobs created from the manifest at Template
+// instead of scaling Deployment, which then names the Jobs.
+type JobConfig struct {
+	Template              string `json:"template"`
+	MessagesPerJob        int    `json:"messagesPerJob"`
+	SuccessfulJobsHistory int    `json:"successfulJobsHistory"`
+	FailedJobsHistory     int    `json:"failedJobsHistory"`
+}
+
+// BudgetConfig limits a target to PodHours, or to Cost at PodHourlyPrice,
+// per Period.
+type BudgetConfig struct {
+	PodHours       float64             `json:"podHours"`
+	Cost           float64             `json:"cost"`
+	PodHourlyPrice float64             `json:"podHourlyPrice"`
+	Period         policy.BudgetPeriod `json:"period"`
+	Pacing         policy.BudgetPacing `json:"pacing"`
+}
+
+// budgetPodHours returns the budget in pod-hours, or zero if there is no
+// budget.
+func (c BudgetConfig) budgetPodHours() float64 {
+	if c.Cost > 0 && c.PodHourlyPrice > 0 {
+		return c.Cost / c.PodHourlyPrice
+	}
+	return c.PodHours
+}
+
+// flagTarget returns the target described by the command line flags.
+func flagTarget() Target {
+	queues := append([]QueueConfig{}, sqsQueues...)
+	if sqsQueueUrl != "" {
+		queues = append([]QueueConfig{{URL: sqsQueueUrl, Weight: 1}}, queues...)
+	}
+
+	// Naming the instance turns on ownership claims
+	competition := competitionPolicy
+	if competition == "" && instanceID != "" {
+		competition = policy.Override
+	}
+
+	return Target{
+		Deployment: kubernetesDeploymentName,
+		Kind:       kubernetesKind,
+		Namespace:  kubernetesNamespace,
+		MinPods:    minPods,
+		MaxPods:    maxPods,
+
+		Queues:           queues,
+		QueueAggregation: queueAggregation,
+		VisibleWeight:    visibleWeight,
+		InFlightWeight:   inFlightWeight,
+		DelayedWeight:    delayedWeight,
+
+		MessageGroupsMetric: messageGroupsMetric,
+
+		PollInterval:      unversioned.Duration{Duration: pollInterval},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: scaleUpCoolPeriod},
+		ScaleDownCoolDown: unversioned.Duration{Duration: scaleDownCoolPeriod},
+		ScaleUpMessages:   scaleUpMessages,
+		ScaleDownMessages: scaleDownMessages,
+
+		TargetMessagesPerPod: targetMessagesPerPod,
+		ScaleSteps:           scaleSteps,
+		PID: PIDConfig{
+			Enabled:       pidEnabled,
+			Setpoint:      pidSetpoint,
+			Kp:            pidKp,
+			Ki:            pidKi,
+			Kd:            pidKd,
+			IntegralLimit: pidIntegralLimit,
+		},
+		Predictive: PredictiveConfig{
+			Model:            predictive,
+			PodStartupTime:   unversioned.Duration{Duration: podStartupTime},
+			Window:           unversioned.Duration{Duration: predictiveWindow},
+			Season:           unversioned.Duration{Duration: seasonLength},
+			HoltWintersAlpha: holtWintersAlpha,
+			HoltWintersBeta:  holtWintersBeta,
+			HoltWintersGamma: holtWintersGamma,
+		},
+		Rate: RateConfig{
+			Enabled:   rateBased,
+			Window:    unversioned.Duration{Duration: rateWindow},
+			DrainTime: unversioned.Duration{Duration: drainTime},
+		},
+		Conditioning: ConditioningConfig{
+			Smoothing:     smoothing,
+			EWMAAlpha:     ewmaAlpha,
+			MedianSamples: medianSamples,
+			ConfirmPolls:  confirmPolls,
+		},
+		Throughput: ThroughputConfig{
+			Enabled:   learnThroughput,
+			Window:    unversioned.Duration{Duration: throughputWindow},
+			Smoothing: throughputSmoothing,
+			DrainTime: unversioned.Duration{Duration: targetDrainTime},
+		},
+
+		MaxMessageAge: unversioned.Duration{Duration: maxMessageAge},
+		DeadLetter: DeadLetterConfig{
+			MaxRatio: maxDeadLetterRatio,
+			Window:   unversioned.Duration{Duration: deadLetterWindow},
+		},
+		ScaleToZeroAfter:   unversioned.Duration{Duration: scaleToZeroAfter},
+		ActivationReplicas: activationReplicas,
+		ReadinessTimeout:   unversioned.Duration{Duration: readinessTimeout},
+
+		ScaleUpStabilization:   unversioned.Duration{Duration: scaleUpStabilization},
+		ScaleDownStabilization: unversioned.Duration{Duration: scaleDownStabilization},
+		ScaleUpLimits:          scaleUpLimits,
+		ScaleDownLimits:        scaleDownLimits,
+		ScaleUpSelect:          scaleUpSelect,
+		ScaleDownSelect:        scaleDownSelect,
+
+		Schedules: schedules,
+		Budget: BudgetConfig{
+			PodHours:       budgetPodHours,
+			Cost:           budgetCost,
+			PodHourlyPrice: podHourlyPrice,
+			Period:         budgetPeriod,
+			Pacing:         budgetPacing,
+		},
+
+		Competition: CompetitionConfig{
+			Policy:      competition,
+			YieldPeriod: unversioned.Duration{Duration: yieldPeriod},
+		},
+
+		Job: JobConfig{
+			Template:              jobTemplate,
+			MessagesPerJob:        messagesPerJob,
+			SuccessfulJobsHistory: successfulJobsHistory,
+			FailedJobsHistory:     failedJobsHistory,
+		},
+	}
+}
+
+// loadTargets reads a YAML or JSON config file with a list of targets. Each
+// target starts from defaults, so only the settings that differ from the
+// command line flags need to be given. A config without targets is an error,
+// since the autoscaler would otherwise exit without scaling anything.
+func loadTargets(path string, defaults Target) ([]Target, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read config file")
+	}
+
+	var config struct {
+		Targets []json.RawMessage `json:"targets"`
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "Failed to parse config file")
+	}
+
+	var targets []Target
+	for i, raw := range config.Targets {
+		t := defaults
+		t.Queues = nil
+		t.Schedules = nil
+
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse target %d", i)
+		}
+
+		targets = append(targets, t)
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("Config file has no targets")
+	}
+
+	return targets, nil
+}
+
+// String returns the name of the target, or its namespace and deployment if
+// it has no name.
+func (t *Target) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Namespace + "/" + t.Deployment
+}
+
+// Validate checks that the target is complete and its settings are
+// consistent.
+func (t *Target) Validate() error {
+	if t.Deployment == "" {
+		return errors.Errorf("Target %s has no deployment", t)
+	}
+
+	if len(t.Queues) == 0 {
+		return errors.Errorf("Target %s has no queues", t)
+	}
+
+	for _, q := range t.Queues {
+		if q.URL == "" {
+			return errors.Errorf("Target %s has a queue without a url", t)
+		}
+		if q.Weight <= 0 {
+			return errors.Errorf("Target %s needs a positive weight for queue %s", t, q.URL)
+		}
+	}
+
+	if t.MessageGroupsMetric != "" {
+		if _, err := sqs.ParseMetric(t.MessageGroupsMetric); err != nil {
+			return errors.Wrapf(err, "Target %s has an invalid message groups metric", t)
+		}
+	}
+
+	if t.PollInterval.Duration <= 0 {
+		return errors.Errorf("Target %s needs a positive poll interval", t)
+	}
+
+	if t.MinPods > t.MaxPods {
+		return errors.Errorf("Target %s has min pods above max pods", t)
+	}
+
+	for i := range t.Schedules {
+		s := &t.Schedules[i]
+		if l := s.Apply(t.limits()); l.MinPods > l.MaxPods {
+			return errors.Errorf("Target %s has min pods above max pods during schedule %s", t, s)
+		}
+	}
+
+	switch t.Predictive.Model {
+	case "", "linear", "holt-winters":
+	default:
+		return errors.Errorf("Target %s has unknown predictive model %q", t, t.Predictive.Model)
+	}
+
+	if t.Predictive.Model != "" && t.TargetMessagesPerPod <= 0 {
+		return errors.Errorf("Target %s needs target messages per pod for predictive scaling", t)
+	}
+
+	if t.Throughput.Enabled && t.Throughput.DrainTime.Duration <= 0 {
+		return errors.Errorf("Target %s needs a positive drain time to size replicas from the learned throughput", t)
+	}
+
+	if _, err := policy.NewSmoother(t.Conditioning.Smoothing, t.Conditioning.EWMAAlpha, t.Conditioning.MedianSamples); err != nil {
+		return errors.Wrapf(err, "Target %s has invalid smoothing", t)
+	}
+
+	if t.DeadLetter.MaxRatio < 0 || t.DeadLetter.MaxRatio > 1 {
+		return errors.Errorf("Target %s needs a dead-letter ratio between 0 and 1", t)
+	}
+
+	if t.Budget.PodHours > 0 && t.Budget.Cost > 0 {
+		return errors.Errorf("Target %s has a budget in both pod-hours and cost", t)
+	}
+
+	if t.Budget.Cost > 0 && t.Budget.PodHourlyPrice <= 0 {
+		return errors.Errorf("Target %s needs a pod hourly price for a budget in cost", t)
+	}
+
+	if t.Throughput.Enabled && (t.Throughput.Smoothing <= 0 || t.Throughput.Smoothing > 1) {
+		return errors.Errorf("Target %s needs a throughput smoothing between 0 and 1", t)
+	}
+
+	if t.Competition.Policy == policy.Yield && t.Competition.YieldPeriod.Duration < 0 {
+		return errors.Errorf("Target %s has a negative yield period", t)
+	}
+
+	if t.Job.Template != "" {
+		if _, err := scale.LoadJobTemplate(t.Job.Template); err != nil {
+			return errors.Wrapf(err, "Target %s has an invalid job template", t)
+		}
+
+		if t.Job.MessagesPerJob <= 0 {
+			return errors.Errorf("Target %s needs at least one message per job", t)
+		}
+
+		if t.Job.SuccessfulJobsHistory < 0 || t.Job.FailedJobsHistory < 0 {
+			return errors.Errorf("Target %s has a negative jobs history", t)
+		}
+	}
+
+	return nil
+}
+
+// NewPodAutoScaler returns the autoscaler for the target's deployment.
+func (t *Target) NewPodAutoScaler(client scale.KubeClient) *scale.PodAutoScaler {
+	return &scale.PodAutoScaler{
+		Client:          client,
+		Min:             t.MinPods,
+		Max:             t.MaxPods,
+		Deployment:      t.Deployment,
+		Kind:            t.Kind,
+		Namespace:       t.Namespace,
+		ScaleUpLimits:   t.ScaleUpLimits,
+		ScaleDownLimits: t.ScaleDownLimits,
+		ScaleUpSelect:   t.ScaleUpSelect,
+		ScaleDownSelect: t.ScaleDownSelect,
+		Log:             t.log(),
+	}
+}
+
+// NewJobScaler returns the scaler that runs the target's backlog as Jobs.
+func (t *Target) NewJobScaler(client scale.JobClient) (*scale.JobScaler, error) {
+	template, err := scale.LoadJobTemplate(t.Job.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scale.JobScaler{
+		Client:                client,
+		Namespace:             t.Namespace,
+		Name:                  t.Deployment,
+		Template:              template,
+		Max:                   t.MaxPods,
+		MessagesPerJob:        t.Job.MessagesPerJob,
+		SuccessfulJobsHistory: t.Job.SuccessfulJobsHistory,
+		FailedJobsHistory:     t.Job.FailedJobsHistory,
+		Log:                   t.log(),
+	}, nil
+}
+
+// NewQueue returns the target's queue, or the aggregate of its queues when it
+// has more than one.
+func (t *Target) NewQueue() sqs.Queue {
+	weights := sqs.Weights{
+		Visible:  t.VisibleWeight,
+		InFlight: t.InFlightWeight,
+		Delayed:  t.DelayedWeight,
+	}
+
+	// Validate has already checked the metric
+	groups, _ := sqs.ParseMetric(t.MessageGroupsMetric)
+
+	newClient := func(q QueueConfig) *sqs.SqsClient {
+		region := q.Region
+		if region == "" {
+			region = awsRegion
+		}
+
+		client := sqs.NewSqsClient(q.URL, region)
+		client.Weights = weights
+		client.MessageGroupsMetric = groups
+		return client
+	}
+
+	if len(t.Queues) == 1 {
+		return newClient(t.Queues[0])
+	}
+
+	multi := &sqs.MultiQueue{Aggregation: t.QueueAggregation}
+	for _, q := range t.Queues {
+		multi.Queues = append(multi.Queues, sqs.WeightedQueue{Queue: newClient(q), Weight: q.Weight})
+	}
+
+	return multi
+}
+
+// limits returns the target's limits outside of any schedule.
+func (t *Target) limits() policy.Limits {
+	return policy.Limits{
+		MinPods:              t.MinPods,
+		MaxPods:              t.MaxPods,
+		ScaleUpMessages:      t.ScaleUpMessages,
+		ScaleDownMessages:    t.ScaleDownMessages,
+		TargetMessagesPerPod: t.TargetMessagesPerPod,
+	}
+}
+
+// newThroughput builds the target's throughput estimator, starting from the
+// throughput saved on the deployment by a previous run, or nil if learning
+// the throughput is disabled.
+func (t *Target) newThroughput(p *scale.PodAutoScaler) *policy.Throughput {
+	if !t.Throughput.Enabled {
+		return nil
+	}
+
+	var initial float64
+
+	saved, err := p.Annotation(throughputAnnotation)
+	if err != nil {
+		t.log().Errorf("Failed to get saved throughput: %v", err)
+	} else if saved != "" {
+		if initial, err = strconv.ParseFloat(saved, 64); err != nil {
+			t.log().Errorf("Ignoring invalid saved throughput %q", saved)
+			initial = 0
+		} else {
+			t.log().Infof("Starting from saved throughput: %s messages/s per pod", saved)
+		}
+	}
+
+	throughput := policy.NewThroughput(t.Throughput.Window.Duration, t.Throughput.Smoothing, initial)
+	throughput.Log = t.log()
+	return throughput
+}
+
+// newBudget builds the target's budget, resuming the consumption saved on the
+// deployment by a previous run, or nil if the target has no budget.
+func (t *Target) newBudget(p *scale.PodAutoScaler) *policy.Budget {
+	podHours := t.Budget.budgetPodHours()
+	if podHours <= 0 {
+		return nil
+	}
+
+	budget := policy.NewBudget(podHours, t.Budget.Period)
+	budget.Pacing = t.Budget.Pacing
+	budget.Log = t.log()
+
+	saved, err := p.Annotation(budgetAnnotation)
+	if err != nil {
+		t.log().Errorf("Failed to get saved budget: %v", err)
+	} else if saved != "" {
+		if err := budget.Restore(saved, time.Now()); err != nil {
+			t.log().Errorf("Ignoring saved budget: %v", err)
+		}
+	}
+
+	return budget
+}
+
+func (t *Target) log() *log.Entry {
+	return log.WithField("target", t.String())
+}
+
+// newPredictive builds the target's predictive policy, or nil if predictive
+// scaling is disabled.
+func (t *Target) newPredictive() *policy.Predictive {
+	c := t.Predictive
+	size := int(c.Window.Duration / t.PollInterval.Duration)
+
+	var predictor *policy.Predictive
+
+	switch c.Model {
+	case "linear":
+		predictor = policy.NewPredictive(policy.LinearTrend{}, c.PodStartupTime.Duration, size)
+	case "holt-winters":
+		season := int(c.Season.Duration / t.PollInterval.Duration)
+		if size < 2*season {
+			size = 2 * season
+		}
+
+		forecaster := policy.HoltWinters{
+			Alpha:        c.HoltWintersAlpha,
+			Beta:         c.HoltWintersBeta,
+			Gamma:        c.HoltWintersGamma,
+			SeasonLength: season,
+		}
+		predictor = policy.NewPredictive(forecaster, c.PodStartupTime.Duration, size)
+	default:
+		return nil
+	}
+
+	predictor.Log = t.log()
+	return predictor
+}
+
+// Run polls the target's queue and scales its deployment until the process
+// exits.
+func (t *Target) Run(p *scale.PodAutoScaler, queue sqs.Queue) {
+	logger := t.log()
+
+	lastScaleUpTime := time.Now()
+	lastScaleDownTime := time.Now()
+
+	scaleTo := func(current int, desired int) {
+		if desired > current {
+			if lastScaleUpTime.Add(t.ScaleUpCoolDown.Duration).After(time.Now()) {
+				logger.Info("Waiting for cool down, skipping scale up ")
+				return
+			}
+
+			scaled, err := p.ScaleTo(desired)
+			if err != nil {
+				logger.Errorf("Failed scaling up: %v", err)
+				return
+			}
+			// A request the limits left at the current replicas does not
+			// start a cool down
+			if !scaled {
+				logger.Infof("Scale up to %d limited to the current %d replicas", desired, current)
+				return
+			}
+
+			lastScaleUpTime = time.Now()
+		}
+
+		if desired < current {
+			if lastScaleDownTime.Add(t.ScaleDownCoolDown.Duration).After(time.Now()) {
+				logger.Info("Waiting for cool down, skipping scale down")
+				return
+			}
+
+			scaled, err := p.ScaleTo(desired)
+			if err != nil {
+				logger.Errorf("Failed scaling down: %v", err)
+				return
+			}
+			// A request the limits left at the current replicas does not
+			// start a cool down
+			if !scaled {
+				logger.Infof("Scale down to %d limited to the current %d replicas", desired, current)
+				return
+			}
+
+			lastScaleDownTime = time.Now()
+		}
+	}
+
+	var pid *policy.PID
+	if t.PID.Enabled {
+		pid = policy.NewPID(float64(t.PID.Setpoint), t.PID.Kp, t.PID.Ki, t.PID.Kd, t.PID.IntegralLimit, p.Min, p.Max)
+		pid.Log = logger
+	}
+
+	predictor := t.newPredictive()
+
+	var zero *policy.ScaleToZero
+	if t.ScaleToZeroAfter.Duration > 0 {
+		zero = policy.NewScaleToZero(t.ScaleToZeroAfter.Duration, t.ActivationReplicas)
+		zero.Log = logger
+	}
+
+	var latency *policy.LatencySLO
+	if t.MaxMessageAge.Duration > 0 {
+		latency = &policy.LatencySLO{SLO: t.MaxMessageAge.Duration, Log: logger}
+	}
+
+	// Validate has already checked the smoothing
+	smoother, _ := policy.NewSmoother(t.Conditioning.Smoothing, t.Conditioning.EWMAAlpha, t.Conditioning.MedianSamples)
+
+	var confirmation *policy.Confirmation
+	if t.Conditioning.ConfirmPolls > 1 {
+		confirmation = &policy.Confirmation{Polls: t.Conditioning.ConfirmPolls}
+	}
+
+	var poison *policy.PoisonDetector
+	if t.DeadLetter.MaxRatio > 0 {
+		poison = policy.NewPoisonDetector(t.DeadLetter.MaxRatio, t.DeadLetter.Window.Duration)
+		poison.Log = logger
+	}
+
+	var readiness *policy.ReadinessWait
+	if t.ReadinessTimeout.Duration > 0 {
+		readiness = policy.NewReadinessWait(t.ReadinessTimeout.Duration)
+		readiness.Log = logger
+	}
+
+	var competition *policy.Competition
+	if t.Competition.Policy != "" {
+		competition = policy.NewCompetition(t.Competition.Policy, t.Competition.YieldPeriod.Duration)
+		competition.Log = logger
+	}
+	owner := instanceID
+	if owner == "" {
+		owner, _ = os.Hostname()
+	}
+	var competitors []string
+	var lastCompetitorCheck time.Time
+	hpaFailed, claimFailed := false, false
+
+	// Whether the rollout past its progress deadline was already reported
+	deadlineExceeded := false
+
+	var stabilizer *policy.Stabilizer
+	if t.ScaleUpStabilization.Duration > 0 || t.ScaleDownStabilization.Duration > 0 {
+		stabilizer = policy.NewStabilizer(t.ScaleUpStabilization.Duration, t.ScaleDownStabilization.Duration)
+		stabilizer.Log = logger
+	}
+
+	var rate *policy.RatePolicy
+	if t.Rate.Enabled {
+		rate = policy.NewRatePolicy(t.ScaleUpMessages, t.ScaleDownMessages, t.Rate.DrainTime.Duration, t.Rate.Window.Duration)
+		rate.Log = logger
+	}
+
+	throughput := t.newThroughput(p)
+	if throughput != nil {
+		throughputMetric.Set(t.String(), expvarFloat(throughput.PerPod))
+	}
+	var lastThroughputSave time.Time
+
+	budget := t.newBudget(p)
+	budgetMax := -1
+	var lastBudgetSave time.Time
+
+	var schedule *policy.Schedule
+
+	for {
+		select {
+		case <-time.After(t.PollInterval.Duration):
+			{
+				now := time.Now()
+
+				limits := t.limits()
+				if active := t.Schedules.Active(now); active != schedule {
+					if active != nil {
+						logger.Infof("Schedule %s is active", active)
+					} else {
+						logger.Infof("Schedule %s ended", schedule)
+					}
+					schedule = active
+				}
+				if schedule != nil {
+					limits = schedule.Apply(limits)
+					logger.Debugf("Applying schedule %s: %+v", schedule, limits)
+				}
+
+				p.Min, p.Max = limits.MinPods, limits.MaxPods
+				if pid != nil {
+					pid.Min, pid.Max = limits.MinPods, limits.MaxPods
+				}
+				if rate != nil {
+					rate.ScaleUpMessages, rate.ScaleDownMessages = limits.ScaleUpMessages, limits.ScaleDownMessages
+				}
+
+				backlog, err := queue.Backlog()
+				if err != nil {
+					logger.Errorf("Failed to get SQS messages: %v", err)
+					continue
+				}
+
+				numMessages := backlog.Messages
+				if smoother != nil {
+					numMessages = int(math.Ceil(smoother.Smooth(float64(backlog.Messages))))
+					logger.Infof("%d messages in queue, %d after %s smoothing", backlog.Messages, numMessages, t.Conditioning.Smoothing)
+				}
+
+				current, err := p.CurrentReplicas()
+				if err != nil {
+					logger.Errorf("Failed to get current replicas: %v", err)
+					continue
+				}
+
+				if budget != nil {
+					budget.Observe(current, now)
+					budgetMetric.Set(t.String(), expvarFloat(budget.Remaining()))
+
+					if max := budget.MaxReplicas(p.Min, now); max != budgetMax {
+						logger.Infof("Budget limits max pods to %d, %.2f of %.2f pod-hours remaining%s", max, budget.Remaining(), budget.PodHours, t.remainingCost(budget))
+						budgetMax = max
+					}
+
+					if budgetMax < p.Max {
+						p.Max = budgetMax
+					}
+					if p.Min > p.Max {
+						p.Min = p.Max
+					}
+
+					if now.Sub(lastBudgetSave) >= annotationSaveInterval {
+						if err := p.Annotate(budgetAnnotation, budget.State()); err != nil {
+							logger.Errorf("Failed to save budget: %v", err)
+						} else {
+							lastBudgetSave = now
+						}
+					}
+				}
+
+				if throughput != nil && throughput.Observe(backlog.Messages, current, now) {
+					throughputMetric.Set(t.String(), expvarFloat(throughput.PerPod))
+
+					if now.Sub(lastThroughputSave) >= annotationSaveInterval {
+						if err := p.Annotate(throughputAnnotation, strconv.FormatFloat(throughput.PerPod, 'f', 3, 64)); err != nil {
+							logger.Errorf("Failed to save throughput: %v", err)
+						} else {
+							lastThroughputSave = now
+						}
+					}
+				}
+
+				if competition != nil {
+					var change string
+					if last, ok := p.ExternalChange(current); ok {
+						change = fmt.Sprintf("Replicas changed from %d to %d by someone else", last, current)
+						externalChangesMetric.Add(t.String(), 1)
+					}
+
+					// Competitors are looked for, and the claim renewed, at
+					// most once per interval to keep api calls down
+					if now.Sub(lastCompetitorCheck) >= competitorCheckInterval {
+						lastCompetitorCheck = now
+						competitors = nil
+
+						if hpa, err := p.HorizontalPodAutoscaler(); err != nil {
+							// Only logged once, as it keeps failing without
+							// permission to list them
+							if !hpaFailed {
+								logger.Warnf("Failed to check for competing horizontal pod autoscalers: %v", err)
+							}
+							hpaFailed = true
+						} else {
+							hpaFailed = false
+							if hpa != "" {
+								competitors = append(competitors, "HorizontalPodAutoscaler "+hpa)
+							}
+						}
+
+						other, claimed, err := p.Owner()
+						if err != nil {
+							logger.Errorf("Failed to get owner: %v", err)
+						} else if other != "" && other != owner && now.Sub(claimed) < t.ownerTimeout() {
+							competitors = append(competitors, "autoscaler instance "+other)
+						} else {
+							other = ""
+						}
+
+						if err == nil && (other == "" || competition.TakesOver()) {
+							if err := p.Claim(owner, now); err != nil {
+								// Only logged once, as it keeps failing
+								// without permission to patch
+								if !claimFailed {
+									logger.Warnf("Failed to claim ownership: %v", err)
+								}
+								claimFailed = true
+							} else {
+								claimFailed = false
+							}
+						}
+
+						competitorsMetric.Set(t.String(), expvarInt(int64(len(competitors))))
+					}
+
+					if competition.Observe(change, competitors, now) {
+						logger.Infof("Yielding to competing scaling, leaving %d replicas alone", current)
+						continue
+					}
+				}
+
+				status, err := p.Status()
+				if err != nil {
+					logger.Errorf("Failed to get status: %v", err)
+					continue
+				}
+
+				// Scaling in the middle of a rollout muddles its surge math
+				if ok, reason := p.RolloutInProgress(status); ok {
+					logger.Infof("Rollout in progress, deferring scaling: %s", reason)
+					continue
+				}
+				if status != nil && status.ProgressDeadlineExceeded {
+					if !deadlineExceeded {
+						logger.Warn("Rollout exceeded its progress deadline, scaling anyway")
+					}
+					deadlineExceeded = true
+				} else {
+					deadlineExceeded = false
+				}
+
+				if zero != nil {
+					if replicas, ok := zero.Replicas(backlog.Attributes, current, now); ok {
+						if replicas == 0 && current > 0 {
+							if err := p.ScaleToZero(); err != nil {
+								logger.Errorf("Failed scaling to zero: %v", err)
+								continue
+							}
+
+							lastScaleDownTime = time.Now()
+						}
+
+						// Activation bypasses the scale up cool down
+						if replicas > 0 {
+							if _, err := p.ScaleTo(replicas); err != nil {
+								logger.Errorf("Failed activating from zero: %v", err)
+								continue
+							}
+
+							lastScaleUpTime = time.Now()
+						}
+
+						continue
+					}
+				}
+
+				desired := current
+
+				switch {
+				case pid != nil:
+					desired = pid.DesiredReplicas(numMessages, now)
+				case predictor != nil && limits.TargetMessagesPerPod > 0:
+					// Never size for less than the backlog that is already there
+					forecast := math.Max(float64(numMessages), predictor.Observe(numMessages, now))
+					desired = desiredReplicas(int(math.Ceil(forecast)), limits.TargetMessagesPerPod, p.Min, p.Max)
+				case limits.TargetMessagesPerPod > 0:
+					desired = desiredReplicas(numMessages, limits.TargetMessagesPerPod, p.Min, p.Max)
+				case throughput != nil && throughput.Learned():
+					desired = throughput.DesiredReplicas(numMessages, t.Throughput.DrainTime.Duration)
+				case len(t.ScaleSteps) > 0:
+					if adjustment, ok := t.ScaleSteps.Adjustment(numMessages); ok {
+						logger.Infof("%d messages in queue, applying step adjustment %s", numMessages, adjustment)
+						desired = adjustment.Apply(current)
+					}
+				case rate != nil:
+					switch rate.Direction(numMessages, now) {
+					case policy.Up:
+						desired = current + 1
+					case policy.Down:
+						desired = current - 1
+					}
+				default:
+					if numMessages >= limits.ScaleUpMessages {
+						desired = current + 1
+					}
+					if numMessages <= limits.ScaleDownMessages {
+						desired = current - 1
+					}
+				}
+
+				if confirmation != nil {
+					direction := policy.Hold
+					if desired > current {
+						direction = policy.Up
+					}
+					if desired < current {
+						direction = policy.Down
+					}
+
+					if !confirmation.Confirm(direction) {
+						logger.Infof("Waiting for %d consecutive polls to confirm scale %s", t.Conditioning.ConfirmPolls, direction)
+						desired = current
+					}
+				}
+
+				if latency != nil {
+					age, err := queue.AgeOfOldestMessage()
+					if err != nil {
+						logger.Errorf("Failed to get age of oldest message: %v", err)
+					} else if latency.Direction(age) == policy.Up && desired <= current {
+						desired = current + 1
+					}
+				}
+
+				if poison != nil {
+					deadLetters, err := queue.DeadLetters(t.DeadLetter.Window.Duration)
+					if err != nil {
+						logger.Errorf("Failed to get dead-letter queue: %v", err)
+					} else if poison.Poisoned(deadLetters, now) && desired > current {
+						logger.Errorf("Suppressing scale up from %d to %d replicas while messages are failing", current, desired)
+						desired = current
+					}
+				}
+
+				desired = p.Bound(desired)
+
+				if t.MessageGroupsMetric != "" && desired > current {
+					groups, ok, err := queue.ActiveMessageGroups()
+					if err != nil {
+						logger.Errorf("Failed to get active message groups: %v", err)
+					} else if ok && desired > groups {
+						logger.Infof("Capping scale up to %d active message groups", groups)
+						desired = p.Bound(groups)
+						if desired < current {
+							desired = current
+						}
+					}
+				}
+
+				if stabilizer != nil {
+					desired = stabilizer.Stabilize(desired, current, now)
+				}
+
+				// The wait is updated on every poll so that it sees the gap close
+				if readiness != nil && status != nil {
+					if wait, reason := readiness.Wait(current, int(status.AvailableReplicas), now); wait && desired > current {
+						logger.Infof("Waiting for replicas to become available, skipping scale up to %d: %s", desired, reason)
+						desired = current
+					}
+				}
+
+				scaleTo(current, desired)
+			}
+		}
+	}
+}
+
+const (
+	// throughputAnnotation is the deployment annotation the learned
+	// throughput is saved in, so that it survives restarts.
+	throughputAnnotation = "kube-sqs-autoscaler/throughput"
+
+	// budgetAnnotation is the deployment annotation the budget used in the
+	// current period is saved in.
+	budgetAnnotation = "kube-sqs-autoscaler/budget"
+
+	// annotationSaveInterval is the minimum time between saves of the state
+	// kept in annotations.
+	annotationSaveInterval = time.Minute
+)
+
+// competitorCheckInterval is the minimum time between checks for competing
+// controllers, which also renew the ownership claim.
+var competitorCheckInterval = time.Minute
+
+// ownerTimeout is how long the ownership claim of another autoscaler
+// instance holds without being renewed.
+func (t *Target) ownerTimeout() time.Duration {
+	interval := competitorCheckInterval
+	if t.PollInterval.Duration > interval {
+		interval = t.PollInterval.Duration
+	}
+	return 3 * interval
+}
+
+// throughputMetric is the learned throughput of each target, served with the
+// other metrics on /debug/vars.
+var throughputMetric = expvar.NewMap("throughput")
+
+// budgetMetric is the pod-hours left in the budget of each target.
+var budgetMetric = expvar.NewMap("budget_remaining_pod_hours")
+
+// competitorsMetric is the number of controllers and other autoscaler
+// instances found scaling the resource of each target.
+var competitorsMetric = expvar.NewMap("competitors")
+
+// externalChangesMetric counts the changes of the replicas of each target
+// made by someone else.
+var externalChangesMetric = expvar.NewMap("external_replica_changes")
+
+// remainingCost describes the remaining budget in cost, if the budget is
+// given in cost.
+func (t *Target) remainingCost(budget *policy.Budget) string {
+	if t.Budget.Cost <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%.2f of %.2f)", budget.Remaining()*t.Budget.PodHourlyPrice, t.Budget.Cost)
+}
+
+func expvarFloat(v float64) *expvar.Float {
+	f := new(expvar.Float)
+	f.Set(v)
+	return f
+}
+
+func expvarInt(v int64) *expvar.Int {
+	i := new(expvar.Int)
+	i.Set(v)
+	return i
+}
+
+type queueFlags []QueueConfig
+
+func (q *queueFlags) String() string {
+	if q == nil {
+		return ""
+	}
+
+	var specs []string
+	for _, c := range *q {
+		specs = append(specs, fmt.Sprintf("%s,region=%s,weight=%g", c.URL, c.Region, c.Weight))
+	}
+	return strings.Join(specs, " ")
+}
+
+// Set implements flag.Value, adding a queue in the form
+// url[,region=region][,weight=weight] each time the flag is given.
+func (q *queueFlags) Set(s string) error {
+	fields := strings.Split(s, ",")
+	c := QueueConfig{URL: fields[0], Weight: 1}
+
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return errors.Errorf("Invalid queue option %q, expected key=value", field)
+		}
+
+		switch parts[0] {
+		case "region":
+			c.Region = parts[1]
+		case "weight":
+			weight, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return errors.Errorf("Invalid queue weight %q", parts[1])
+			}
+			c.Weight = weight
+		default:
+			return errors.Errorf("Unknown queue option %q", parts[0])
+		}
+	}
+
+	*q = append(*q, c)
+	return nil
+}
+
+// RunJobs works off the backlog of the target's queues with Jobs, polling
+// forever. The Jobs are sized from the visible and in-flight messages, since
+// running Jobs still hold the messages they received. Max pods, including
+// the max pods of an active schedule, limits the running Jobs.
+func (t *Target) RunJobs(j *scale.JobScaler, queue sqs.Queue) {
+	logger := t.log()
+
+	for {
+		select {
+		case <-time.After(t.PollInterval.Duration):
+			{
+				limits := t.limits()
+				if schedule := t.Schedules.Active(time.Now()); schedule != nil {
+					limits = schedule.Apply(limits)
+				}
+				j.Max = limits.MaxPods
+
+				backlog, err := queue.Backlog()
+				if err != nil {
+					logger.Errorf("Failed to get SQS messages: %v", err)
+					continue
+				}
+
+				if _, err := j.Scale(backlog.Attributes.Visible + backlog.Attributes.InFlight); err != nil {
+					logger.Errorf("Failed running jobs: %v", err)
+				}
+			}
+		}
+	}
+}
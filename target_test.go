@@ -0,0 +1,657 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/autoscaling"
+
+	"github.com/Wattpad/kube-sqs-autoscaler/policy"
+	"github.com/Wattpad/kube-sqs-autoscaler/scale"
+	mainsqs "github.com/Wattpad/kube-sqs-autoscaler/sqs"
+)
+
+func writeConfig(t *testing.T, config string) string {
+	f, err := ioutil.TempFile("", "kube-sqs-autoscaler")
+	assert.Nil(t, err)
+
+	_, err = f.WriteString(config)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	return f.Name()
+}
+
+func TestLoadTargets(t *testing.T) {
+	path := writeConfig(t, `
+targets:
+- deployment: images
+  namespace: media
+  maxPods: 20
+  queues:
+  - url: https://sqs.us-east-1.amazonaws.com/1/images
+  scaleUpCoolDown: 1m
+  scaleSteps: "0:10:-1,100::+50%"
+  scaleUpLimits: "10/1m"
+- name: emails
+  deployment: mailer
+  queues:
+  - url: https://sqs.us-east-1.amazonaws.com/1/high
+    weight: 4
+  - url: https://sqs.eu-west-1.amazonaws.com/1/bulk
+    region: eu-west-1
+  queueAggregation: weighted-sum
+  pid:
+    enabled: true
+    setpoint: 500
+`)
+	defer os.Remove(path)
+
+	defaults := Target{
+		Namespace:         "default",
+		MinPods:           1,
+		MaxPods:           5,
+		Queues:            []QueueConfig{{URL: "https://sqs.us-east-1.amazonaws.com/1/flag", Weight: 1}},
+		QueueAggregation:  mainsqs.Sum,
+		PollInterval:      unversioned.Duration{Duration: 5 * time.Second},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 10 * time.Second},
+		ScaleDownCoolDown: unversioned.Duration{Duration: 30 * time.Second},
+		ScaleUpMessages:   100,
+		PID:               PIDConfig{Kp: 0.01, Setpoint: 100},
+	}
+
+	targets, err := loadTargets(path, defaults)
+	assert.Nil(t, err)
+	assert.Len(t, targets, 2)
+
+	images := targets[0]
+	assert.Equal(t, "media/images", images.String())
+	assert.Equal(t, 1, images.MinPods, "Unset fields should keep the defaults")
+	assert.Equal(t, 20, images.MaxPods)
+	assert.Equal(t, time.Minute, images.ScaleUpCoolDown.Duration)
+	assert.Equal(t, 30*time.Second, images.ScaleDownCoolDown.Duration)
+	assert.Len(t, images.ScaleSteps, 2)
+	assert.Equal(t, scale.RateLimits{{Value: 10, Period: time.Minute}}, images.ScaleUpLimits)
+	assert.Equal(t, []QueueConfig{{URL: "https://sqs.us-east-1.amazonaws.com/1/images", Weight: 1}}, images.Queues, "Queues should not be merged with the flag queues")
+
+	emails := targets[1]
+	assert.Equal(t, "emails", emails.String())
+	assert.Equal(t, "default", emails.Namespace)
+	assert.Equal(t, mainsqs.WeightedSum, emails.QueueAggregation)
+	assert.Len(t, emails.Queues, 2)
+	assert.Equal(t, "eu-west-1", emails.Queues[1].Region)
+	assert.Equal(t, 4.0, emails.Queues[0].Weight)
+	assert.Equal(t, 1.0, emails.Queues[1].Weight, "Queues without a weight should default to 1")
+	assert.True(t, emails.PID.Enabled)
+	assert.Equal(t, 500, emails.PID.Setpoint)
+	assert.Equal(t, 0.01, emails.PID.Kp)
+
+	// loading must not modify the defaults shared by every target
+	assert.Equal(t, 100, defaults.PID.Setpoint)
+}
+
+func TestLoadTargetsInvalid(t *testing.T) {
+	for _, config := range []string{
+		"targets: [",
+		"targets:\n- scaleSteps: \"0:10\"\n",
+		"targets:\n- scaleUpLimits: \"10\"\n",
+		"targets:\n- queueAggregation: avg\n",
+		"targets:\n- scaleDownSelect: median\n",
+		"targets: []\n",
+		"# no targets\n",
+	} {
+		path := writeConfig(t, config)
+		_, err := loadTargets(path, Target{})
+		os.Remove(path)
+
+		assert.NotNil(t, err, config)
+	}
+
+	_, err := loadTargets("/nonexistent/config.yaml", Target{})
+	assert.NotNil(t, err)
+}
+
+func TestTargetValidate(t *testing.T) {
+	valid := func() Target {
+		return Target{
+			Deployment:   "test",
+			Namespace:    "test",
+			MinPods:      1,
+			MaxPods:      5,
+			Queues:       []QueueConfig{{URL: "example.com", Weight: 1}},
+			PollInterval: unversioned.Duration{Duration: time.Second},
+		}
+	}
+
+	target := valid()
+	assert.Nil(t, target.Validate())
+
+	target = valid()
+	target.Deployment = ""
+	assert.NotNil(t, target.Validate())
+
+	target = valid()
+	target.Queues = nil
+	assert.NotNil(t, target.Validate())
+
+	target = valid()
+	target.Queues = []QueueConfig{{Region: "us-east-1"}}
+	assert.NotNil(t, target.Validate())
+
+	target = valid()
+	target.Queues = []QueueConfig{{URL: "example.com", Weight: 0}}
+	assert.NotNil(t, target.Validate())
+
+	target = valid()
+	target.PollInterval.Duration = 0
+	assert.NotNil(t, target.Validate())
+
+	target = valid()
+	target.Competition = CompetitionConfig{Policy: policy.Yield, YieldPeriod: unversioned.Duration{Duration: -time.Minute}}
+	assert.NotNil(t, target.Validate())
+
+	target = valid()
+	target.MinPods = 10
+	assert.NotNil(t, target.Validate())
+
+	target = valid()
+	target.Predictive.Model = "arima"
+	assert.NotNil(t, target.Validate())
+
+	target = valid()
+	target.Predictive.Model = "linear"
+	assert.NotNil(t, target.Validate(), "Predictive scaling should require target messages per pod")
+
+	target.TargetMessagesPerPod = 10
+	assert.Nil(t, target.Validate())
+
+	target = valid()
+	assert.Nil(t, target.Schedules.Set("* 0-7 * * *;min-pods=8"))
+	assert.NotNil(t, target.Validate(), "Schedules should not raise min pods above max pods")
+
+	target = valid()
+	target.Job = JobConfig{Template: "/nonexistent", MessagesPerJob: 1}
+	assert.NotNil(t, target.Validate(), "The job template should be readable")
+
+	target.Job.Template = writeConfig(t, jobManifest)
+	defer os.Remove(target.Job.Template)
+	assert.Nil(t, target.Validate())
+
+	target.Job.MessagesPerJob = 0
+	assert.NotNil(t, target.Validate())
+}
+
+func TestTargetsRunIndependently(t *testing.T) {
+	newTarget := func(name string, scaleUpMessages int) *Target {
+		return &Target{
+			Deployment:        name,
+			Namespace:         "test",
+			MinPods:           1,
+			MaxPods:           5,
+			PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+			ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+			ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+			ScaleUpMessages:   scaleUpMessages,
+			ScaleDownMessages: 0,
+		}
+	}
+
+	busy := newTarget("busy", 100)
+	quiet := newTarget("quiet", 1000)
+
+	busyScaler := NewMockPodAutoScaler(busy.Deployment, busy.Namespace, busy.MaxPods, busy.MinPods)
+	quietScaler := NewMockPodAutoScaler(quiet.Deployment, quiet.Namespace, quiet.MaxPods, quiet.MinPods)
+
+	busyQueue := NewMockSqsClient()
+	quietQueue := NewMockSqsClient()
+
+	busyQueue.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+	quietQueue.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go busy.Run(busyScaler, busyQueue)
+	go quiet.Run(quietScaler, quietQueue)
+
+	time.Sleep(time.Second)
+
+	deployment := busyScaler.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(5), deployment.Replicas, "Busy target should scale up to its max")
+
+	deployment = quietScaler.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(3), deployment.Replicas, "Quiet target should keep its replicas under its own threshold")
+}
+
+func TestTargetRunSchedule(t *testing.T) {
+	var schedules policy.Schedules
+	assert.Nil(t, schedules.Set("* * * * *;name=always;max-pods=4;scale-up-messages=200"))
+
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           10,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   1000,
+		Schedules:         schedules,
+	}
+
+	p := NewMockPodAutoScaler(target.Deployment, target.Namespace, target.MaxPods, target.MinPods)
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go target.Run(p, s)
+
+	time.Sleep(time.Second)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(4), deployment.Replicas, "Number of replicas should follow the threshold and max pods of the active schedule")
+}
+
+func TestTargetRunSavedThroughput(t *testing.T) {
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           10,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   1000,
+		Throughput: ThroughputConfig{
+			Enabled:   true,
+			Window:    unversioned.Duration{Duration: time.Minute},
+			Smoothing: 0.2,
+			DrainTime: unversioned.Duration{Duration: 10 * time.Second},
+		},
+	}
+
+	p := NewMockPodAutoScaler(target.Deployment, target.Namespace, target.MaxPods, target.MinPods)
+	assert.Nil(t, p.Annotate(throughputAnnotation, "8"))
+
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go target.Run(p, s)
+
+	// 500 messages at 8 messages/s per pod take 7 pods to drain in 10s
+	time.Sleep(time.Second)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(7), deployment.Replicas, "Number of replicas should be sized from the saved throughput")
+}
+
+func TestTargetRunPoisonMessages(t *testing.T) {
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           10,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   600,
+		DeadLetter: DeadLetterConfig{
+			MaxRatio: 0.5,
+			Window:   unversioned.Duration{Duration: time.Minute},
+		},
+	}
+
+	p := NewMockPodAutoScaler(target.Deployment, target.Namespace, target.MaxPods, target.MinPods)
+	s := NewMockSqsClient()
+
+	// The mock serves the same attributes for the dead-letter queue, so it
+	// grows with the main queue while nothing is deleted
+	redrive := aws.String(`{"deadLetterTargetArn":"arn:aws:sqs:us-east-1:123456789012:test-dlq","maxReceiveCount":5}`)
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500"), "RedrivePolicy": redrive},
+	})
+
+	go target.Run(p, s)
+
+	time.Sleep(300 * time.Millisecond)
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("1000"), "RedrivePolicy": redrive},
+	})
+
+	time.Sleep(time.Second)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(3), deployment.Replicas, "Number of replicas should not scale up while messages go to the dead-letter queue")
+}
+
+func TestTargetRunMessageGroups(t *testing.T) {
+	target := &Target{
+		Deployment:          "test",
+		Namespace:           "test",
+		MinPods:             1,
+		MaxPods:             10,
+		PollInterval:        unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:     unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown:   unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:     100,
+		MessageGroupsMetric: "Orders/ActiveMessageGroups",
+	}
+
+	p := NewMockPodAutoScaler(target.Deployment, target.Namespace, target.MaxPods, target.MinPods)
+	s := NewMockSqsClient()
+	s.MessageGroupsMetric = mainsqs.Metric{Namespace: "Orders", MetricName: "ActiveMessageGroups"}
+	s.CloudWatch = &MockCloudWatch{Age: 5 * time.Second}
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("5000"), "FifoQueue": aws.String("true")},
+	})
+
+	go target.Run(p, s)
+
+	// The mock reports the same Maximum for every metric, 5 groups here
+	time.Sleep(time.Second)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(5), deployment.Replicas, "Number of replicas should be capped at the active message groups")
+}
+
+func TestTargetRunBudget(t *testing.T) {
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           10,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpMessages:   100,
+		Budget: BudgetConfig{
+			Cost:           200,
+			PodHourlyPrice: 2.5,
+			Period:         policy.Monthly,
+		},
+	}
+
+	// The month's budget of 80 pod-hours is already spent
+	p := NewMockPodAutoScaler(target.Deployment, target.Namespace, target.MaxPods, target.MinPods)
+	start := policy.Monthly.Start(time.Now())
+	assert.Nil(t, p.Annotate(budgetAnnotation, start.Format(time.RFC3339)+"/80"))
+
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go target.Run(p, s)
+
+	time.Sleep(time.Second)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(0), deployment.Replicas, "Number of replicas should drop to zero once the budget is spent")
+
+	saved, _ := p.Annotation(budgetAnnotation)
+	assert.Contains(t, saved, start.Format(time.RFC3339))
+}
+
+func TestTargetRunConditioning(t *testing.T) {
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           10,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   100,
+		Conditioning: ConditioningConfig{
+			Smoothing:     "median",
+			MedianSamples: 5,
+			ConfirmPolls:  3,
+		},
+	}
+
+	p := NewMockPodAutoScaler(target.Deployment, target.Namespace, target.MaxPods, target.MinPods)
+	s := NewMockSqsClient()
+
+	go target.Run(p, s)
+
+	// A spike lasting two polls is below the median of five samples
+	time.Sleep(250 * time.Millisecond)
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("5000")},
+	})
+	time.Sleep(150 * time.Millisecond)
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("50")},
+	})
+
+	time.Sleep(time.Second)
+	deployment := p.Client.(*MockKubeClient).Deployment()
+	assert.Equal(t, int32(3), deployment.Replicas, "Number of replicas should not react to a short spike")
+}
+
+func TestTargetRunStatefulSet(t *testing.T) {
+	target := &Target{
+		Deployment:        "cache",
+		Kind:              scale.KindStatefulSet,
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           5,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   100,
+	}
+
+	client := NewMockKubeClient()
+	p := target.NewPodAutoScaler(client)
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go target.Run(p, s)
+
+	time.Sleep(time.Second)
+	assert.Equal(t, int32(5), client.StatefulSet().Replicas, "Statefulset should scale up to max pods")
+	assert.Equal(t, int32(3), client.Deployment().Replicas, "Deployment should be left alone")
+}
+
+func TestTargetRunRollout(t *testing.T) {
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           5,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   100,
+	}
+
+	client := NewMockKubeClient()
+	client.Status = &scale.Status{Generation: 2, ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 1, AvailableReplicas: 3}
+	p := target.NewPodAutoScaler(client)
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go target.Run(p, s)
+
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(3), client.Deployment().Replicas, "Scaling should be deferred during a rollout")
+
+	client.Status = nil
+
+	time.Sleep(time.Second)
+	assert.Equal(t, int32(5), client.Deployment().Replicas, "Scaling should resume once the rollout completes")
+}
+
+func TestTargetRunReadiness(t *testing.T) {
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           5,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   100,
+		ReadinessTimeout:  unversioned.Duration{Duration: 800 * time.Millisecond},
+	}
+
+	client := NewMockKubeClient()
+	client.Status = &scale.Status{Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 2}
+	p := target.NewPodAutoScaler(client)
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go target.Run(p, s)
+
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(3), client.Deployment().Replicas, "Scale ups should wait for replicas to become available")
+
+	time.Sleep(time.Second)
+	assert.Equal(t, int32(5), client.Deployment().Replicas, "Scale ups should go ahead after the timeout")
+}
+
+func TestTargetRunCompetition(t *testing.T) {
+	defer func(interval time.Duration) { competitorCheckInterval = interval }(competitorCheckInterval)
+	competitorCheckInterval = 100 * time.Millisecond
+
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           5,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   100,
+		Competition: CompetitionConfig{
+			Policy:      policy.Yield,
+			YieldPeriod: unversioned.Duration{Duration: time.Hour},
+		},
+	}
+
+	client := NewMockKubeClient()
+	client.HPAs = []autoscaling.HorizontalPodAutoscaler{{
+		ObjectMeta: api.ObjectMeta{Name: "test-hpa"},
+		Spec: autoscaling.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscaling.CrossVersionObjectReference{Kind: "Deployment", Name: "test"},
+		},
+	}}
+	p := target.NewPodAutoScaler(client)
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go target.Run(p, s)
+
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(3), client.Deployment().Replicas, "Scaling should yield to the HorizontalPodAutoscaler")
+
+	client.HPAs = nil
+	time.Sleep(time.Second)
+	assert.Equal(t, int32(5), client.Deployment().Replicas, "Scaling should resume once the HorizontalPodAutoscaler is gone")
+	assert.Contains(t, client.Deployment().Annotations[scale.OwnerAnnotation], "/", "The deployment should be claimed")
+
+	client.Deployment().Replicas = 2
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(2), client.Deployment().Replicas, "Scaling should yield after a change by someone else")
+}
+
+func TestTargetRunWithoutCompetition(t *testing.T) {
+	target := &Target{
+		Deployment:        "test",
+		Namespace:         "test",
+		MinPods:           1,
+		MaxPods:           5,
+		PollInterval:      unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleUpCoolDown:   unversioned.Duration{Duration: 100 * time.Millisecond},
+		ScaleDownCoolDown: unversioned.Duration{Duration: time.Hour},
+		ScaleUpMessages:   100,
+	}
+
+	client := NewMockKubeClient()
+	client.HPAs = []autoscaling.HorizontalPodAutoscaler{{
+		ObjectMeta: api.ObjectMeta{Name: "test-hpa"},
+		Spec: autoscaling.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscaling.CrossVersionObjectReference{Kind: "Deployment", Name: "test"},
+		},
+	}}
+	p := target.NewPodAutoScaler(client)
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{"ApproximateNumberOfMessages": aws.String("500")},
+	})
+
+	go target.Run(p, s)
+
+	time.Sleep(time.Second)
+	assert.Equal(t, int32(5), client.Deployment().Replicas, "Without a competition policy, competitors should be ignored")
+	assert.Empty(t, client.Deployment().Annotations[scale.OwnerAnnotation], "Without a competition policy, the deployment should not be claimed")
+}
+
+const jobManifest = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: worker
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: worker
+        image: example.com/worker
+`
+
+func TestTargetRunJobs(t *testing.T) {
+	path := writeConfig(t, jobManifest)
+	defer os.Remove(path)
+
+	target := &Target{
+		Deployment:   "worker",
+		Namespace:    "test",
+		MinPods:      1,
+		MaxPods:      5,
+		Queues:       []QueueConfig{{URL: "example.com", Weight: 1}},
+		PollInterval: unversioned.Duration{Duration: 100 * time.Millisecond},
+		Job: JobConfig{
+			Template:       path,
+			MessagesPerJob: 2,
+		},
+	}
+	assert.Nil(t, target.Validate())
+
+	client := &MockJobClient{}
+	j, err := target.NewJobScaler(client)
+	assert.Nil(t, err)
+
+	s := NewMockSqsClient()
+	s.Client.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		Attributes: map[string]*string{
+			"ApproximateNumberOfMessages":           aws.String("3"),
+			"ApproximateNumberOfMessagesNotVisible": aws.String("4"),
+		},
+	})
+
+	go target.RunJobs(j, s)
+
+	time.Sleep(time.Second)
+	jobs := client.Created()
+	assert.Len(t, jobs, 4, "Visible and in-flight messages should be shared by jobs, and running jobs counted")
+	assert.Equal(t, "worker-", jobs[0].GenerateName)
+	assert.Equal(t, "worker", jobs[0].Labels[scale.JobLabel])
+}